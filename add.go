@@ -0,0 +1,783 @@
+package main
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// host to be created
+type newHost struct {
+    hostName        string      // host_name of the new host
+    address         string      // address of the new host
+    alias           string      // alias of the new host (optional)
+    attrs           def         // extra attributes of the new host (optional)
+}
+
+// parse new hosts from --host/--address/--alias or from --file
+// file format is one 'host_name address [alias]' per line, or csv with a header line
+// 'host_name,address,alias,extra attributes...' where extra columns are attribute names
+func parseNewHosts(visited map[string]interface{}) ([]newHost, error) {
+    hosts := []newHost{}
+    if fval, ok := visited["file"]; ok {
+        data, err := ioutil.ReadFile(fval.([]string)[0]); if err != nil {
+            return nil, err
+        }
+        if strings.HasPrefix(strings.TrimSpace(string(data)), "host_name") {
+            csvHosts, err := parseCSVHosts(string(data)); if err != nil {
+                return nil, err
+            }
+            hosts = append(hosts, csvHosts...)
+        } else {
+            for _, line := range strings.Split(string(data), "\n") {
+                line = strings.TrimSpace(line)
+                if line == "" || strings.HasPrefix(line, "#") {
+                    continue
+                }
+                fields := []string{}
+                if strings.Contains(line, ",") {
+                    for _, v := range strings.Split(line, ",") {
+                        fields = append(fields, strings.TrimSpace(v))
+                    }
+                } else {
+                    fields = strings.Fields(line)
+                    if len(fields) > 2 {
+                        fields = append(fields[:2], strings.Join(fields[2:], " "))
+                    }
+                }
+                h := newHost{hostName: fields[0], attrs: def{}}
+                if len(fields) > 1 {
+                    h.address = fields[1]
+                }
+                if len(fields) > 2 {
+                    h.alias = fields[2]
+                }
+                hosts = append(hosts, h)
+            }
+        }
+    }
+    if hval, ok := visited["host"]; ok {
+        names := hval.([]string)
+        addrs, aliases := []string{}, []string{}
+        if aval, ok := visited["address"]; ok {
+            addrs = aval.([]string)
+        }
+        if aval, ok := visited["alias"]; ok {
+            aliases = aval.([]string)
+        }
+        if len(addrs) != len(names) {
+            return nil, errors.New("--address must have one value for every --host")
+        }
+        if len(aliases) != 0 && len(aliases) != len(names) {
+            return nil, errors.New("--alias must have one value for every --host")
+        }
+        for i, name := range names {
+            h := newHost{hostName: name, address: addrs[i], attrs: def{}}
+            if len(aliases) > 0 {
+                h.alias = aliases[i]
+            }
+            hosts = append(hosts, h)
+        }
+    }
+    if len(hosts) == 0 {
+        return nil, errors.New("--host or --file option is required")
+    }
+    // extra attributes from --set apply to every new host, csv columns take precedence
+    if sval, ok := visited["set"]; ok {
+        attrs, err := parseAttrFlags(sval.([]string)); if err != nil {
+            return nil, err
+        }
+        for _, h := range hosts {
+            for attr, val := range attrs {
+                if !h.attrs.attrExist(attr) {
+                    v := append(attrVal{}, *val...)
+                    h.attrs[attr] = &v
+                }
+            }
+        }
+    }
+    return hosts, nil
+}
+
+// parse csv hosts, header line define the column names
+func parseCSVHosts(data string) ([]newHost, error) {
+    r := csv.NewReader(strings.NewReader(data))
+    r.Comment = '#'
+    r.TrimLeadingSpace = true
+    records, err := r.ReadAll(); if err != nil {
+        return nil, err
+    }
+    header := records[0]
+    hosts := []newHost{}
+    for _, record := range records[1:] {
+        h := newHost{attrs: def{}}
+        for i, col := range header {
+            col = strings.TrimSpace(col)
+            val := strings.TrimSpace(record[i])
+            if val == "" {
+                continue
+            }
+            switch col {
+            case "host_name":
+                h.hostName = val
+            case "address":
+                h.address = val
+            case "alias":
+                h.alias = val
+            default:
+                v := splitAttrVal(col, strings.Replace(val, ";", ",", -1))
+                h.attrs[col] = &v
+            }
+        }
+        if h.hostName == "" {
+            return nil, fmt.Errorf("host_name is missing in csv record '%v'", strings.Join(record, ","))
+        }
+        hosts = append(hosts, h)
+    }
+    return hosts, nil
+}
+
+// parse attr=value flag values, the flag parser split values on comma so values without '=' belong to the previous attr
+func parseAttrFlags(vals []string) (def, error) {
+    attrs := def{}
+    last := ""
+    for _, v := range vals {
+        if i := strings.Index(v, "="); i > 0 {
+            last = strings.TrimSpace(v[:i])
+            val := splitAttrVal(last, v[i+1:])
+            attrs[last] = &val
+            continue
+        }
+        if last == "" {
+            return nil, fmt.Errorf("expected attr=value, got '%v'", v)
+        }
+        if isSingleValueAttr(last) {
+            attrs[last] = &attrVal{attrs[last].ToString() + "," + v}
+        } else {
+            attrs[last].Add(strings.TrimSpace(v))
+        }
+    }
+    return attrs, nil
+}
+
+// validate new hosts before changing anything
+func validateNewHosts(objDefs *obj, hosts []newHost) {
+    seen := attrVal{}
+    for _, h := range hosts {
+        if isHostExist(&objDefs.hostDefs, h.hostName) || seen.Has(h.hostName) {
+            err := errors.New("host already exist")
+            fmt.Println(&duplicateObjectError{err, "host", h.hostName})
+            exit(1)
+        }
+        if h.address == "" {
+            err := fmt.Errorf("address of host '%v' is required", h.hostName)
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        seen.Add(h.hostName)
+    }
+}
+
+// check if a host is already defined
+func isHostExist(hd *defs, hostname string) bool {
+    if _, exist := (*hd)[hostname]; exist {
+        return true
+    }
+    for _, def := range *hd {
+        if def.attrExist("host_name") && def["host_name"].Has(hostname) {
+            return true
+        }
+    }
+    return false
+}
+
+// helper function to print addition
+func printAddition(id string, codeName string, attrVal string, addType string, bflags attrVal) {
+    switch addType {
+    // print added attribute value
+    case "val":
+        if bflags.Has("color") {
+            fmt.Printf("%vAdd%v:%v[%v]%v: added %v to %v\n", Green, RST, Blue, codeName, RST, attrVal, id)
+        } else {
+            fmt.Printf("Add:[%v]: added %v to %v\n", codeName, attrVal, id)
+        }
+    // print added object definition
+    case "def":
+        if bflags.Has("color") {
+            fmt.Printf("%vAdd%v:%v[%v DEFINITION]%v: added object definition %v to %v\n", Green, RST, Blue, codeName, RST, id, attrVal)
+        } else {
+            fmt.Printf("Add:[%v DEFINITION]: added object definition %v to %v\n", codeName, id, attrVal)
+        }
+    }
+}
+
+// add a host to every list that names the source host explicitly (regex and hostgroups based associations are left as is)
+func addExplicitHostName(d *defs, attrName string, codeName string, src string, hostname string, bflags attrVal) {
+    for _, id := range d.sortedIDs() {
+        def := (*d)[id]
+        if !def.attrExist(attrName) {
+            continue
+        }
+        if def[attrName].Has(src) && !def[attrName].Has(hostname) {
+            def[attrName].Add(hostname)
+            printAddition(id, codeName, hostname, "val", bflags)
+        }
+        if def[attrName].Has("!"+src) && !def[attrName].Has("!"+hostname) {
+            def[attrName].Add("!" + hostname)
+            printAddition(id, codeName, "!"+hostname, "val", bflags)
+        }
+    }
+}
+
+// check if a byte can be part of a host name
+func isHostNameByte(c byte) bool {
+    return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.'
+}
+
+// replace a host name where it is a whole word of s, e.g. web1 in "web1 (backup of web12)" but not in web12
+func replaceHostName(s string, oldName string, newName string) string {
+    if oldName == "" {
+        return s
+    }
+    out := ""
+    for {
+        i := strings.Index(s, oldName)
+        if i < 0 {
+            return out + s
+        }
+        end := i + len(oldName)
+        if (i == 0 || !isHostNameByte(s[i-1])) && (end == len(s) || !isHostNameByte(s[end])) {
+            out += s[:i] + newName
+        } else {
+            out += s[:end]
+        }
+        s = s[end:]
+    }
+}
+
+// clone a host definition and its explicit hostgroups/services association
+func cloneHost(objDefs *obj, src string, h newHost, after string, bflags attrVal) {
+    newDef := copyDef(objDefs.hostDefs[src])
+    newDef["host_name"] = &attrVal{h.hostName}
+    newDef["address"] = &attrVal{h.address}
+    if h.alias != "" {
+        newDef["alias"] = &attrVal{h.alias}
+    }
+    for attr, val := range h.attrs {
+        newDef[attr] = val
+    }
+    // alias and display_name usually contain the host name, as a whole word
+    for _, attr := range []string{"alias", "display_name"} {
+        if newDef.attrExist(attr) && !h.attrs.attrExist(attr) && (h.alias == "" || attr != "alias") {
+            newDef[attr] = &attrVal{replaceHostName(newDef[attr].ToString(), src, h.hostName)}
+        }
+    }
+    f, _ := objDefs.findBlock("host", src)
+    blk := objDefs.addObjDef("host", h.hostName, newDef, f.name, after)
+    objDefs.formatLike(blk, "host", src)
+    printAddition(h.hostName, "HOST", f.name, "def", bflags)
+    if bflags.Has("verbose") {
+        objDefs.hostDefs.printDef("host", h.hostName)
+    }
+    addExplicitHostName(&objDefs.hostgroupDefs, "members", "HGRP MEMBERS", src, h.hostName, bflags)
+    addExplicitHostName(&objDefs.serviceDefs, "host_name", "SVC HOSTNAME", src, h.hostName, bflags)
+    addExplicitHostName(&objDefs.serviceTempDefs, "host_name", "SVCTMPL HOSTNAME", src, h.hostName, bflags)
+}
+
+// add host(s) based on an existing host
+func addHostLike(objDefs *obj, visited map[string]interface{}, bflags attrVal) {
+    src := visited["like"].([]string)[0]
+    if _, exist := objDefs.hostDefs[src]; !exist {
+        err := errors.New("host not found")
+        fmt.Println(&NotFoundError{err, "Fatal", src})
+        exit(1)
+    }
+    hosts, err := parseNewHosts(visited); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    validateNewHosts(objDefs, hosts)
+    after := src
+    for _, h := range hosts {
+        cloneHost(objDefs, src, h, after, bflags)
+        after = h.hostName
+    }
+    fmt.Printf("\nNum of hosts: %v\n\n", len(hosts))
+}
+
+// find the config file of a new object according to the placement rule, return the file name and the object to place the new object after
+// 'template' rule place the object next to the objects using the same template, otherwise the rule is a path pattern
+// (relative to nagios configs directory) with {name}, {template} and {hostgroup} placeholders
+func placeObj(objDefs *obj, kind string, rule string, tmpl string, vars map[string]string) (string, string, error) {
+    if rule == "template" {
+        var best *cfgFile
+        counts, last := make(map[*cfgFile]int), make(map[*cfgFile]string)
+        for _, f := range objDefs.files {
+            for _, b := range f.blocks {
+                d, exist := (*objDefs.defsOf(kind))[b.id]
+                if b.kind == kind && exist && d.attrExist("use") && d["use"].Has(tmpl) {
+                    counts[f] += 1
+                    last[f] = b.id
+                }
+            }
+            if counts[f] > 0 && (best == nil || counts[f] > counts[best]) {
+                best = f
+            }
+        }
+        if best != nil {
+            return best.name, last[best], nil
+        }
+        // nothing use the template yet, place the object in the template file
+        if f, _ := objDefs.findBlock(kind+"template", tmpl); f != nil {
+            return f.name, "", nil
+        }
+        return "", "", fmt.Errorf("unable to find a config file for %v using template '%v'", kind, tmpl)
+    }
+    name := rule
+    for k, v := range vars {
+        if !strings.Contains(name, "{"+k+"}") {
+            continue
+        }
+        // values come from csv files and inventories, they must not move the file elsewhere
+        if v == "" || v == "." || v == ".." || strings.ContainsAny(v, `/\`) {
+            return "", "", fmt.Errorf("%v '%v' can not be used in the config file path of placement rule '%v'", k, v, rule)
+        }
+        name = strings.Replace(name, "{"+k+"}", v, -1)
+    }
+    if filepath.Ext(name) != ".cfg" {
+        return "", "", fmt.Errorf("placement rule '%v' must end with .cfg", rule)
+    }
+    // absolute rules must point into the config directory as well, compared as clean absolute paths
+    root, err := filepath.Abs(objDefs.path); if err != nil {
+        return "", "", err
+    }
+    abs := name
+    if !filepath.IsAbs(abs) {
+        abs = filepath.Join(root, abs)
+    }
+    abs = filepath.Clean(abs)
+    rel, err := filepath.Rel(root, abs); if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+        return "", "", fmt.Errorf("placement rule '%v' places objects outside of the nagios config directory", rule)
+    }
+    // config files are named relative to the config directory as given, whatever the rule spelling
+    name = filepath.Join(objDefs.path, rel)
+    after := ""
+    for _, f := range objDefs.files {
+        if fabs, err := filepath.Abs(f.name); err != nil || fabs != abs {
+            continue
+        }
+        name = f.name
+        for _, b := range f.blocks {
+            if b.kind == kind {
+                after = b.id
+            }
+        }
+    }
+    return name, after, nil
+}
+
+// add host(s) based on a host template
+func addHostTemplate(objDefs *obj, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    tmpl := visited["template"].([]string)[0]
+    hosts, err := parseNewHosts(visited); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    validateNewHosts(objDefs, hosts)
+    hostgroups := attrVal{}
+    if hval, ok := visited["hostgroups"]; ok {
+        hostgroups = hval.([]string)
+    }
+    addHostsFromTemplate(objDefs, tmpl, hosts, hostgroups, enabled["placement"].(string), bflags)
+    fmt.Printf("\nNum of hosts: %v\n\n", len(hosts))
+}
+
+// create host definitions from a host template, nothing is added if any host is invalid
+func addHostsFromTemplate(objDefs *obj, tmpl string, hosts []newHost, hostgroups attrVal, placement string, bflags attrVal) {
+    if _, exist := objDefs.hostTempDefs[tmpl]; !exist {
+        err := errors.New("host template not found")
+        fmt.Println(&NotFoundError{err, "Fatal", tmpl})
+        exit(1)
+    }
+    // template hostgroups are kept with additive inheritance
+    tmplDef := buildUseTree(&objDefs.hostTempDefs, tmpl, "host template", objDefs.hostTempDefs[tmpl], attrVal{tmpl}).resolve()
+    newDefs := []def{}
+    failed := false
+    for _, h := range hosts {
+        d := def{}
+        for attr, val := range h.attrs {
+            d[attr] = val
+        }
+        d["use"] = &attrVal{tmpl}
+        d["host_name"] = &attrVal{h.hostName}
+        d["address"] = &attrVal{h.address}
+        d["alias"] = &attrVal{h.hostName}
+        if h.alias != "" {
+            d["alias"] = &attrVal{h.alias}
+        }
+        if len(hostgroups) > 0 {
+            if !d.attrExist("hostgroups") {
+                d["hostgroups"] = &attrVal{}
+            }
+            for _, hg := range hostgroups {
+                if !d["hostgroups"].Has(hg) {
+                    d["hostgroups"].Add(hg)
+                }
+            }
+        }
+        if d.attrExist("hostgroups") {
+            for _, hg := range *d["hostgroups"] {
+                if _, exist := objDefs.hostgroupDefs[strings.TrimLeft(hg, "+!")]; !exist {
+                    err := errors.New("hostgroup not found")
+                    fmt.Println(&NotFoundError{err, "Fatal", hg})
+                    failed = true
+                }
+            }
+            if tmplDef.attrExist("hostgroups") && !strings.HasPrefix((*d["hostgroups"])[0], "+") {
+                (*d["hostgroups"])[0] = "+" + (*d["hostgroups"])[0]
+            }
+        }
+        resolved := buildUseTree(&objDefs.hostTempDefs, h.hostName, "host", d, attrVal{}).resolve()
+        if missing := missingAttr(resolved, requiredHostAttr); len(missing) > 0 {
+            err := errors.New("is missing required attributes")
+            fmt.Println(&missingAttributeError{err, "host", h.hostName, missing})
+            failed = true
+        }
+        newDefs = append(newDefs, d)
+    }
+    if failed {
+        exit(1)
+    }
+    for i, h := range hosts {
+        vars := map[string]string{"name": h.hostName, "host_name": h.hostName, "template": tmpl, "hostgroup": "ungrouped"}
+        if newDefs[i].attrExist("hostgroups") {
+            vars["hostgroup"] = strings.TrimLeft((*newDefs[i]["hostgroups"])[0], "+")
+        }
+        fileName, after, err := placeObj(objDefs, "host", placement, tmpl, vars); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        blk := objDefs.addObjDef("host", h.hostName, newDefs[i], fileName, after)
+        if after != "" {
+            objDefs.formatLike(blk, "host", after)
+        }
+        printAddition(h.hostName, "HOST", fileName, "def", bflags)
+        if bflags.Has("verbose") {
+            objDefs.hostDefs.printDef("host", h.hostName)
+        }
+    }
+}
+
+// hostgroups a host is a member of (members, hostgroup_members and host/template hostgroups)
+func hostgroupsOf(objDefs *obj, hostname string) attrVal {
+    host := findHost(&objDefs.hostDefs, &objDefs.hostTempDefs, hostname)
+    // hostgroups of the host definition keep their '+' additive prefix
+    hostgroups := attrVal{}
+    for _, hg := range findHostGroups(&objDefs.hostgroupDefs, &objDefs.hostTempDefs, host).enabled {
+        hostgroups.Add(strings.TrimLeft(hg, "+"))
+    }
+    return hostgroups
+}
+
+// hosts that are members of a hostgroup
+func hostsOfHostgroup(objDefs *obj, hgName string) (hosts attrVal) {
+    for _, id := range objDefs.hostDefs.sortedIDs() {
+        if hostgroups := hostgroupsOf(objDefs, id); hostgroups.Has(hgName) {
+            hosts.Add(id)
+        }
+    }
+    return hosts
+}
+
+// hosts of the services that are members of a servicegroup
+func hostsOfServicegroup(objDefs *obj, sgName string) (hosts attrVal) {
+    add := func(items ...string) {
+        for _, h := range items {
+            if isHostExist(&objDefs.hostDefs, h) && !hosts.Has(h) {
+                hosts.Add(h)
+            }
+        }
+    }
+    // servicegroup members are host,service pairs
+    if sg := objDefs.servicegroupDefs[sgName]; sg.attrExist("members") {
+        members := *sg["members"]
+        for i := 0; i+1 < len(members); i += 2 {
+            add(members[i])
+        }
+    }
+    for _, id := range objDefs.serviceDefs.sortedIDs() {
+        def := objDefs.serviceDefs[id]
+        if !def.attrExist("servicegroups") || !def["servicegroups"].Has(sgName) {
+            continue
+        }
+        if def.attrExist("host_name") {
+            add(*def["host_name"]...)
+        }
+        if def.attrExist("hostgroup_name") {
+            for _, hg := range *def["hostgroup_name"] {
+                add(hostsOfHostgroup(objDefs, hg)...)
+            }
+        }
+    }
+    return hosts
+}
+
+// find services with the same service_description a host already gets, and the path it gets them through
+func findDuplicateService(objDefs *obj, hostname string, desc string) map[string]string {
+    dups := make(map[string]string)
+    host := findHost(&objDefs.hostDefs, &objDefs.hostTempDefs, hostname)
+    hostgroups := findHostGroups(&objDefs.hostgroupDefs, &objDefs.hostTempDefs, host)
+    services := findServices(&objDefs.serviceDefs, &objDefs.serviceTempDefs, hostgroups, hostname)
+    for id := range services.enabled.m {
+        if objDefs.serviceDefs[id]["service_description"].ToString() != desc {
+            continue
+        }
+        switch {
+        case services.hostName.Has(id):
+            dups[id] = "host_name"
+        case services.hostgroupName.Has(id):
+            dups[id] = "hostgroup_name"
+        default:
+            dups[id] = "service template"
+        }
+    }
+    return dups
+}
+
+// find an existing service definition the new service can share by appending the host(s) to its host_name
+// the definition must be identical (except host_name) and list its hosts explicitly
+func findSharedService(objDefs *obj, d def) string {
+    want := copyDef(d)
+    delete(want, "host_name")
+    for _, id := range objDefs.serviceDefs.sortedIDs() {
+        def := objDefs.serviceDefs[id]
+        if !def.attrExist("host_name") || def.attrExist("hostgroup_name") {
+            continue
+        }
+        explicit := true
+        for _, h := range *def["host_name"] {
+            if strings.ContainsAny(h, "!*?[]()^$|\\") {
+                explicit = false
+            }
+        }
+        have := copyDef(def)
+        delete(have, "host_name")
+        if explicit && equalDef(want, have) {
+            return id
+        }
+    }
+    return ""
+}
+
+// add a service check to hosts, hostgroups or a servicegroup
+func addService(objDefs *obj, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    hval, sh := visited["host"]
+    gval, sg := visited["hostgroup"]
+    sgval, ssg := visited["servicegroup"]
+    tval, st := visited["template"]
+    dval, sd := visited["description"]
+    if !st || !sd {
+        err := errors.New("--template and --description options are required")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    if !sh && !sg && !ssg {
+        err := errors.New("one of --host, --hostgroup or --servicegroup option is required")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    tmpl := tval.([]string)[0]
+    // description and check_command may contain commas
+    desc := strings.Join(dval.([]string), ",")
+    if _, exist := objDefs.serviceTempDefs[tmpl]; !exist {
+        err := errors.New("service template not found")
+        fmt.Println(&NotFoundError{err, "Fatal", tmpl})
+        exit(1)
+    }
+    d := def{}
+    if sval, ok := visited["set"]; ok {
+        attrs, err := parseAttrFlags(sval.([]string)); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        d = attrs
+    }
+    d["use"] = &attrVal{tmpl}
+    d["service_description"] = &attrVal{desc}
+    failed := false
+    if cval, ok := visited["command"]; ok {
+        d["check_command"] = &attrVal{strings.Join(cval.([]string), ",")}
+    }
+    // hosts that will get the service
+    hosts := attrVal{}
+    if sh {
+        d["host_name"] = &attrVal{}
+        for _, h := range hval.([]string) {
+            if !isHostExist(&objDefs.hostDefs, h) {
+                err := errors.New("host not found")
+                fmt.Println(&NotFoundError{err, "Fatal", h})
+                failed = true
+            }
+            d["host_name"].Add(h)
+            hosts.Add(h)
+        }
+    }
+    if sg {
+        d["hostgroup_name"] = &attrVal{}
+        for _, hg := range gval.([]string) {
+            if _, exist := objDefs.hostgroupDefs[hg]; !exist {
+                err := errors.New("hostgroup not found")
+                fmt.Println(&NotFoundError{err, "Fatal", hg})
+                failed = true
+            }
+            d["hostgroup_name"].Add(hg)
+            for _, h := range hostsOfHostgroup(objDefs, hg) {
+                if !hosts.Has(h) {
+                    hosts.Add(h)
+                }
+            }
+        }
+    }
+    if ssg {
+        d["servicegroups"] = &attrVal{}
+        for _, name := range sgval.([]string) {
+            if _, exist := objDefs.servicegroupDefs[name]; !exist {
+                err := errors.New("servicegroup not found")
+                fmt.Println(&NotFoundError{err, "Fatal", name})
+                failed = true
+                continue
+            }
+            d["servicegroups"].Add(name)
+            // servicegroup is the only target, the service is added to the hosts of the servicegroup
+            if !sh && !sg {
+                for _, h := range hostsOfServicegroup(objDefs, name) {
+                    if !hosts.Has(h) {
+                        hosts.Add(h)
+                    }
+                }
+            }
+        }
+        if !sh && !sg {
+            if len(hosts) == 0 && !failed {
+                err := errors.New("servicegroup has no host, use --host or --hostgroup option")
+                fmt.Println(&parsingError{err})
+                exit(1)
+            }
+            d["host_name"] = &attrVal{}
+            d["host_name"].Add(hosts...)
+        }
+    }
+    if !validateNewService(objDefs, d, hosts) || failed {
+        exit(1)
+    }
+    addServiceDef(objDefs, d, sh && !sg, enabled["placement"].(string), bflags)
+}
+
+// validate a new service definition against the hosts that will get it, returns false if it can not be added
+func validateNewService(objDefs *obj, d def, hosts attrVal) bool {
+    failed := false
+    desc := d["service_description"].ToString()
+    if d.attrExist("check_command") {
+        name := strings.TrimSpace(strings.Split(d["check_command"].ToString(), "!")[0])
+        if _, exist := objDefs.commandDefs[name]; !exist {
+            err := errors.New("command not found")
+            fmt.Println(&NotFoundError{err, "Fatal", name})
+            failed = true
+        }
+    }
+    // refuse duplicate service_description the host already gets via another path
+    for _, h := range hosts {
+        dups := findDuplicateService(objDefs, h, desc)
+        ids := []string{}
+        for id := range dups {
+            ids = append(ids, id)
+        }
+        sort.Strings(ids)
+        for _, id := range ids {
+            path := dups[id]
+            err := fmt.Errorf("already associated with the host via %v of '%v' definition", path, displayID(id))
+            fmt.Println(&duplicateObjectError{err, "service", h + " " + desc})
+            failed = true
+        }
+    }
+    resolved := buildUseTree(&objDefs.serviceTempDefs, desc, "service", d, attrVal{}).resolve()
+    if missing := missingAttr(resolved, requiredServiceAttr); len(missing) > 0 {
+        err := errors.New("is missing required attributes")
+        fmt.Println(&missingAttributeError{err, "service", desc, missing})
+        failed = true
+    }
+    return !failed
+}
+
+// add a new service definition, a host target service is appended to an existing identical definition when share is true
+func addServiceDef(objDefs *obj, d def, share bool, placement string, bflags attrVal) {
+    desc := d["service_description"].ToString()
+    tmpl := d["use"].ToString()
+    // host target, append the host(s) to an existing identical definition
+    if share {
+        if id := findSharedService(objDefs, d); id != "" {
+            for _, h := range *d["host_name"] {
+                objDefs.serviceDefs[id]["host_name"].Add(h)
+                printAddition(displayID(id), "SERVICE", h, "val", bflags)
+            }
+            if bflags.Has("verbose") {
+                objDefs.serviceDefs.printDef("service", id)
+            }
+            return
+        }
+    }
+    vars := map[string]string{"name": desc, "service_description": desc, "template": tmpl, "host_name": "", "hostgroup": "ungrouped"}
+    if d.attrExist("host_name") {
+        vars["host_name"] = (*d["host_name"])[0]
+    }
+    if d.attrExist("hostgroup_name") {
+        vars["hostgroup"] = (*d["hostgroup_name"])[0]
+    }
+    fileName, after, err := placeObj(objDefs, "service", placement, tmpl, vars); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    id := uniqueID(objDefs.serviceDefs, desc)
+    blk := objDefs.addObjDef("service", id, d, fileName, after)
+    if after != "" {
+        objDefs.formatLike(blk, "service", after)
+    }
+    printAddition(desc, "SERVICE", fileName, "def", bflags)
+    if bflags.Has("verbose") {
+        objDefs.serviceDefs.printDef("service", id)
+    }
+}
+
+// add nagios objects
+func addCmd(objDefs *obj, pos []string, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    if len(pos) == 0 {
+        err := errors.New("object type is required e.g. 'add host'")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    switch pos[0] {
+    case "host":
+        _, like := visited["like"]
+        _, tmpl := visited["template"]
+        if like && !tmpl {
+            addHostLike(objDefs, visited, bflags)
+        } else if tmpl && !like {
+            addHostTemplate(objDefs, visited, enabled, bflags)
+        } else {
+            err := errors.New("one of --like or --template option is required")
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+    case "service":
+        addService(objDefs, visited, enabled, bflags)
+    default:
+        err := fmt.Errorf("unsupported object type '%v'", pos[0])
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    objDefs.commitChanges(enabled, bflags)
+}
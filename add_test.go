@@ -0,0 +1,51 @@
+package main
+
+import (
+    "path/filepath"
+    "testing"
+)
+
+func TestReplaceHostName(t *testing.T) {
+    tests := []struct {
+        value   string
+        want    string
+    }{
+        {"web1", "web2"},
+        {"web1 (backup of web12)", "web2 (backup of web12)"},
+        {"Web server web1", "Web server web2"},
+        {"web1.example.com", "web1.example.com"},
+        {"web1,web1", "web2,web2"},
+        {"oldweb1", "oldweb1"},
+    }
+    for _, tt := range tests {
+        if got := replaceHostName(tt.value, "web1", "web2"); got != tt.want {
+            t.Errorf("replaceHostName(%q) = %q, want %q", tt.value, got, tt.want)
+        }
+    }
+}
+
+func TestPlaceObjStaysInConfigDir(t *testing.T) {
+    _, root := testConfTree(t)
+    objDefs := loadNagiosData([]string{"nagios"}, ".cfg", []string{".git"})
+    hosts := filepath.Join("nagios", "objects", "hosts.cfg")
+    tests := []struct {
+        rule    string
+        want    string
+        after   string
+        ok      bool
+    }{
+        {"objects/hosts.cfg", hosts, "web01", true},
+        {filepath.Join(root, "objects", "hosts.cfg"), hosts, "web01", true},
+        {root + "/objects/../objects/hosts.cfg", hosts, "web01", true},
+        {"objects/{host_name}.cfg", filepath.Join("nagios", "objects", "new1.cfg"), "", true},
+        {"/tmp/outside/{host_name}.cfg", "", "", false},
+        {filepath.Join(filepath.Dir(root), "x.cfg"), "", "", false},
+        {"../x.cfg", "", "", false},
+    }
+    for _, tt := range tests {
+        name, after, err := placeObj(objDefs, "host", tt.rule, "", map[string]string{"host_name": "new1"})
+        if (err == nil) != tt.ok || name != tt.want || after != tt.after {
+            t.Errorf("placeObj(%q) = %q, %q, %v, want %q, %q, ok %v", tt.rule, name, after, err, tt.want, tt.after, tt.ok)
+        }
+    }
+}
@@ -2,6 +2,11 @@
 Any new custom directives/variables need to be added here otherwise eznagios will flag it as unknown*/
 // Standard attributes for Nagios host object
 package main
+
+import (
+    "strings"
+)
+
 var (
     maxHostAttrLen      = maxObjAttrLength(&hostAttr)
     maxSvcAttrLen       = maxObjAttrLength(&serviceAttr)
@@ -220,8 +225,58 @@ var (
         "_comment",
         "_cacti",
         "_tags"}
+    // attributes nagios require in a host definition (resolved with its templates), '|' separate alternatives
+    requiredHostAttr = []string{
+        "host_name",
+        "alias",
+        "address",
+        "max_check_attempts",
+        "check_period",
+        "contacts|contact_groups",
+        "notification_interval",
+        "notification_period"}
+    // attributes nagios require in a service definition (resolved with its templates)
+    requiredServiceAttr = []string{
+        "host_name|hostgroup_name",
+        "service_description",
+        "check_command",
+        "max_check_attempts",
+        "check_interval",
+        "retry_interval",
+        "check_period",
+        "contacts|contact_groups",
+        "notification_interval",
+        "notification_period"}
+    // attributes that hold a single value (commas are part of the value, not a list separator)
+    singleValueAttr = []string{
+        "alias",
+        "display_name",
+        "address",
+        "check_command",
+        "command_line",
+        "event_handler",
+        "service_description",
+        "notes",
+        "notes_url",
+        "action_url",
+        "icon_image_alt",
+        "email",
+        "pager"}
 )
 
+// check if an attribute hold a single value, custom variables are always single value
+func isSingleValueAttr(attrName string) bool {
+    if strings.HasPrefix(attrName, "_") {
+        return true
+    }
+    for _, v := range singleValueAttr {
+        if v == attrName {
+            return true
+        }
+    }
+    return false
+}
+
 func maxObjAttrLength(a *[]string) int{
     maxLength := len((*a)[0])
     for _,v := range *a {
@@ -232,3 +287,21 @@ func maxObjAttrLength(a *[]string) int{
     return maxLength
 }
 
+
+// find required attributes that are missing from a resolved object definition
+func missingAttr(d def, required []string) []string {
+    missing := []string{}
+    for _, attr := range required {
+        found := false
+        for _, alt := range strings.Split(attr, "|") {
+            if d.attrExist(alt) {
+                found = true
+                break
+            }
+        }
+        if !found {
+            missing = append(missing, attr)
+        }
+    }
+    return missing
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "text/template"
+)
+
+// blueprint file extension
+const blueprintExt = ".tmpl"
+
+// input variable of a blueprint
+type blueprintVar struct {
+    name        string
+    value       string          // default value
+    required    bool            // variable without a default value
+    usage       string
+}
+
+// parameterized nagios objects rendered with text/template
+// header comments declare the blueprint:
+//   # blueprint: <description>
+//   # var <name>[=<default>] <usage>
+type blueprint struct {
+    name        string
+    file        string
+    description string
+    vars        []blueprintVar
+    body        string
+}
+
+// blueprint header variable declaration
+var reBlueprintVar = regexp.MustCompile(`^#\s*var\s+([A-Za-z_][A-Za-z0-9_]*)(=(\S*))?\s*(.*)$`)
+
+// functions available in blueprints
+var blueprintFuncs = template.FuncMap{
+    "lower":    strings.ToLower,
+    "upper":    strings.ToUpper,
+    "replace":  func(old string, new string, s string) string { return strings.Replace(s, old, new, -1) },
+    "split":    func(sep string, s string) []string { return strings.Split(s, sep) },
+    "join":     func(sep string, s []string) string { return strings.Join(s, sep) },
+}
+
+// read a blueprint file
+func readBlueprint(fileName string) (*blueprint, error) {
+    data, err := ioutil.ReadFile(fileName); if err != nil {
+        return nil, err
+    }
+    bp := &blueprint{name: strings.TrimSuffix(filepath.Base(fileName), blueprintExt), file: fileName, body: string(data)}
+    scanner := bufio.NewScanner(bytes.NewReader(data))
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+        if !strings.HasPrefix(line, "#") {
+            break
+        }
+        if m := reBlueprintVar.FindStringSubmatch(line); m != nil {
+            bp.vars = append(bp.vars, blueprintVar{m[1], m[3], m[2] == "", m[4]})
+        } else if i := strings.Index(line, "blueprint:"); i > 0 && strings.TrimSpace(line[1:i]) == "" {
+            bp.description = strings.TrimSpace(line[i+len("blueprint:"):])
+        }
+    }
+    return bp, nil
+}
+
+// find the blueprints of a directory
+func listBlueprints(dir string) ([]*blueprint, error) {
+    files, err := filepath.Glob(filepath.Join(dir, "*"+blueprintExt)); if err != nil {
+        return nil, err
+    }
+    sort.Strings(files)
+    bps := []*blueprint{}
+    for _, f := range files {
+        bp, err := readBlueprint(f); if err != nil {
+            return nil, err
+        }
+        bps = append(bps, bp)
+    }
+    return bps, nil
+}
+
+// render a blueprint, undeclared and missing required variables are refused
+func (bp *blueprint) render(vars map[string]string) (string, error) {
+    data := make(map[string]string)
+    declared := attrVal{}
+    for _, v := range bp.vars {
+        declared.Add(v.name)
+        val, set := vars[v.name]
+        if !set && v.required {
+            return "", fmt.Errorf("variable '%v' of blueprint '%v' is required", v.name, bp.name)
+        }
+        if !set {
+            val = v.value
+        }
+        data[v.name] = val
+    }
+    for name := range vars {
+        if !declared.Has(name) {
+            return "", fmt.Errorf("blueprint '%v' has no variable '%v'", bp.name, name)
+        }
+    }
+    tmpl, err := template.New(bp.name).Funcs(blueprintFuncs).Option("missingkey=error").Parse(bp.body); if err != nil {
+        return "", err
+    }
+    var buf bytes.Buffer
+    if err := tmpl.Execute(&buf, data); err != nil {
+        return "", err
+    }
+    return buf.String(), nil
+}
+
+// parse name=value variables, the flag parser split values on comma so values without '=' belong to the previous variable
+func parseBlueprintVars(vals []string) (map[string]string, error) {
+    vars := make(map[string]string)
+    last := ""
+    for _, v := range vals {
+        if i := strings.Index(v, "="); i > 0 {
+            last = strings.TrimSpace(v[:i])
+            vars[last] = strings.TrimSpace(v[i+1:])
+            continue
+        }
+        if last == "" {
+            return nil, fmt.Errorf("expected name=value, got '%v'", v)
+        }
+        vars[last] += "," + v
+    }
+    return vars, nil
+}
+
+// helper function to print a blueprint
+func printBlueprint(bp *blueprint, bflags attrVal) {
+    name := bp.name
+    if bflags.Has("color") {
+        name = Green + bp.name + RST
+    }
+    fmt.Printf("%v: %v\n", name, bp.description)
+    maxLen := 0
+    for _, v := range bp.vars {
+        if len(v.name) > maxLen {
+            maxLen = len(v.name)
+        }
+    }
+    for _, v := range bp.vars {
+        state := "required"
+        if !v.required {
+            state = fmt.Sprintf("default '%v'", v.value)
+        }
+        fmt.Printf("    %-*v  %v (%v)\n", maxLen, v.name, v.usage, state)
+    }
+}
+
+// single template of a rendered object, blueprint objects are added through the template machinery
+func blueprintTemplate(d def, kind string, id string) (string, error) {
+    if !d.attrExist("use") || len(*d["use"]) != 1 {
+        return "", fmt.Errorf("%v '%v' of the blueprint must use exactly one template", kind, displayID(id))
+    }
+    return d["use"].ToString(), nil
+}
+
+// merge the objects of a rendered blueprint, hosts are added first then their services
+// services without host_name and hostgroup_name are added to the hosts of the blueprint
+func applyBlueprint(objDefs *obj, bp *blueprint, data string, placement string, bflags attrVal) (numHosts int, numServices int) {
+    rendered := newObj()
+    getObjDefs(rendered, data, bp.file)
+    blocks := rendered.files[0].blocks
+    if len(blocks) == 0 {
+        err := fmt.Errorf("blueprint '%v' has no object definition", bp.name)
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    hosts := make(map[string][]newHost)
+    tmpls, hostNames := []string{}, attrVal{}
+    all := []newHost{}
+    for _, b := range blocks {
+        d := (*rendered.defsOf(b.kind))[b.id]
+        if b.kind != "host" && b.kind != "service" {
+            err := fmt.Errorf("unsupported object type '%v' in blueprint '%v', expected host or service", b.kind, bp.name)
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        if b.kind != "host" {
+            continue
+        }
+        tmpl, err := blueprintTemplate(d, "host", b.id); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        h := newHost{hostName: b.id, attrs: copyDef(d)}
+        if d.attrExist("address") {
+            h.address = d["address"].ToString()
+        }
+        if d.attrExist("alias") {
+            h.alias = d["alias"].ToString()
+        }
+        for _, attr := range []string{"use", "host_name", "address", "alias"} {
+            delete(h.attrs, attr)
+        }
+        if _, ok := hosts[tmpl]; !ok {
+            tmpls = append(tmpls, tmpl)
+        }
+        hosts[tmpl] = append(hosts[tmpl], h)
+        hostNames.Add(h.hostName)
+        all = append(all, h)
+    }
+    validateNewHosts(objDefs, all)
+    for _, tmpl := range tmpls {
+        addHostsFromTemplate(objDefs, tmpl, hosts[tmpl], attrVal{}, placement, bflags)
+    }
+    for _, b := range blocks {
+        if b.kind != "service" {
+            continue
+        }
+        d := copyDef(rendered.serviceDefs[b.id])
+        tmpl, err := blueprintTemplate(d, "service", b.id); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        if _, exist := objDefs.serviceTempDefs[tmpl]; !exist {
+            err := errors.New("service template not found")
+            fmt.Println(&NotFoundError{err, "Fatal", tmpl})
+            exit(1)
+        }
+        if !d.attrExist("host_name") && !d.attrExist("hostgroup_name") {
+            if len(hostNames) == 0 {
+                err := fmt.Errorf("service '%v' of blueprint '%v' has no host_name or hostgroup_name", displayID(b.id), bp.name)
+                fmt.Println(&parsingError{err})
+                exit(1)
+            }
+            d["host_name"] = &attrVal{}
+            d["host_name"].Add(hostNames...)
+        }
+        // hosts that will get the service
+        targets := attrVal{}
+        failed := false
+        if d.attrExist("host_name") {
+            for _, h := range *d["host_name"] {
+                if !isHostExist(&objDefs.hostDefs, h) {
+                    err := errors.New("host not found")
+                    fmt.Println(&NotFoundError{err, "Fatal", h})
+                    failed = true
+                }
+                targets.Add(h)
+            }
+        }
+        if d.attrExist("hostgroup_name") {
+            for _, hg := range *d["hostgroup_name"] {
+                if _, exist := objDefs.hostgroupDefs[hg]; !exist {
+                    err := errors.New("hostgroup not found")
+                    fmt.Println(&NotFoundError{err, "Fatal", hg})
+                    failed = true
+                }
+                for _, h := range hostsOfHostgroup(objDefs, hg) {
+                    if !targets.Has(h) {
+                        targets.Add(h)
+                    }
+                }
+            }
+        }
+        if !validateNewService(objDefs, d, targets) || failed {
+            exit(1)
+        }
+        addServiceDef(objDefs, d, !d.attrExist("hostgroup_name"), placement, bflags)
+        numServices += 1
+    }
+    return len(all), numServices
+}
+
+// list, show and apply blueprints
+func blueprintCmd(objDefs *obj, pos []string, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    dir := enabled["blueprints"].(string)
+    if len(pos) == 0 || pos[0] != "list" && len(pos) != 2 {
+        err := errors.New("expected 'blueprint list' or 'blueprint <show|apply> <name>'")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    vars := make(map[string]string)
+    if vval, ok := visited["var"]; ok {
+        var err error
+        vars, err = parseBlueprintVars(vval.([]string)); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+    }
+    if pos[0] == "list" {
+        bps, err := listBlueprints(dir); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        for _, bp := range bps {
+            printBlueprint(bp, bflags)
+        }
+        fmt.Printf("\nNum of blueprints: %v (%v)\n\n", len(bps), dir)
+        return
+    }
+    fileName := filepath.Join(dir, pos[1]+blueprintExt)
+    if !isFileExist(fileName) {
+        err := errors.New("blueprint not found")
+        fmt.Println(&NotFoundError{err, "Fatal", fileName})
+        exit(1)
+    }
+    bp, err := readBlueprint(fileName); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    switch pos[0] {
+    case "show":
+        printBlueprint(bp, bflags)
+        // without variables the blueprint is shown as is
+        out := bp.body
+        if len(vars) > 0 {
+            out, err = bp.render(vars); if err != nil {
+                fmt.Println(&parsingError{err})
+                exit(1)
+            }
+        }
+        fmt.Printf("\n%v\n", strings.TrimSpace(out))
+    case "apply":
+        data, err := bp.render(vars); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        numHosts, numServices := applyBlueprint(objDefs, bp, data, enabled["placement"].(string), bflags)
+        fmt.Printf("\nNum of hosts: %v, services: %v\n\n", numHosts, numServices)
+        objDefs.commitChanges(enabled, bflags)
+    default:
+        err := fmt.Errorf("unknown blueprint action '%v', expected list, show or apply", pos[0])
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+}
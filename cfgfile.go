@@ -0,0 +1,321 @@
+package main
+
+import (
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// layout of a nagios config file, used to write back only the object definitions that changed
+type cfgFile struct {
+    name        string          // path of the config file
+    raw         string          // original content of the config file
+    blocks      []*cfgBlock     // object definitions in file order
+}
+
+// location of an object definition inside a config file
+type cfgBlock struct {
+    kind        string          // defs the object belongs to (host, hosttemplate, service, ...)
+    id          string          // object index in defs
+    origID      string          // object index as loaded from the file (before a rename)
+    start       int             // start offset of the definition in raw data (-1 for new definitions)
+    end         int             // end offset of the definition in raw data
+    orig        def             // copy of the object definition as loaded from the file
+    likeRaw     string          // raw definition used as format of a new definition (e.g. cloned object)
+    likeOrig    def             // object definition of likeRaw
+}
+
+// object attribute line inside a definition; indent, attr name, gap and value
+var reAttrLine = regexp.MustCompile(`^(\s*)([^\s;#]+)(\s+)(.*?)\s*$`)
+
+// register a config file layout
+func (o *obj) addConfFile(name string, raw string) *cfgFile {
+    f := &cfgFile{name: name, raw: raw}
+    o.files = append(o.files, f)
+    return f
+}
+
+// register object definition location inside the config file
+func (f *cfgFile) addBlock(kind string, id string, start int, end int, d def) {
+    f.blocks = append(f.blocks, &cfgBlock{kind: kind, id: id, origID: id, start: start, end: end, orig: copyDef(d)})
+}
+
+// number of object definitions loaded from config files
+func (o *obj) numObjDefs() int {
+    n := 0
+    for _, f := range o.files {
+        n += len(f.blocks)
+    }
+    return n
+}
+
+// get object definitions of a specific kind
+func (o *obj) defsOf(kind string) *defs {
+    switch kind {
+    case "host":
+        return &o.hostDefs
+    case "hosttemplate":
+        return &o.hostTempDefs
+    case "service":
+        return &o.serviceDefs
+    case "servicetemplate":
+        return &o.serviceTempDefs
+    case "hostgroup":
+        return &o.hostgroupDefs
+    case "hostdependency":
+        return &o.hostdependencyDefs
+    case "servicedependency":
+        return &o.servicedependencyDefs
+    case "hostescalation":
+        return &o.hostescalationDefs
+    case "serviceescalation":
+        return &o.serviceescalationDefs
+    case "contact":
+        return &o.contactDefs
+    case "contacttemplate":
+        return &o.contactTempDefs
+    case "contactgroup":
+        return &o.contactgroupDefs
+    case "servicegroup":
+        return &o.servicegroupDefs
+    case "command":
+        return &o.commandDefs
+    case "timeperiod":
+        return &o.timeperiodDefs
+    }
+    return nil
+}
+
+// nagios object type of a kind e.g. hosttemplate -> host
+func objTypeOf(kind string) string {
+    return strings.TrimSuffix(kind, "template")
+}
+
+// deep copy of an object definition
+func copyDef(d def) def {
+    c := def{}
+    for attr, val := range d {
+        v := append(attrVal{}, *val...)
+        c[attr] = &v
+    }
+    return c
+}
+
+// check if two object definitions have the same attributes and values
+func equalDef(a def, b def) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for attr, val := range a {
+        if !b.attrExist(attr) || val.ToString() != b[attr].ToString() {
+            return false
+        }
+    }
+    return true
+}
+
+// find the config file and block of an object definition
+func (o *obj) findBlock(kind string, id string) (*cfgFile, int) {
+    for _, f := range o.files {
+        for i, b := range f.blocks {
+            if b.kind == kind && b.id == id {
+                return f, i
+            }
+        }
+    }
+    return nil, -1
+}
+
+// get config file by name, a new (empty) config file is registered if it does not exist
+func (o *obj) confFile(name string) *cfgFile {
+    for _, f := range o.files {
+        if f.name == name {
+            return f
+        }
+    }
+    return o.addConfFile(name, "")
+}
+
+// add a new object definition to a config file, placed right after the 'after' object if it is in the same file
+func (o *obj) addObjDef(kind string, id string, d def, fileName string, after string) *cfgBlock {
+    (*o.defsOf(kind))[id] = d
+    f := o.confFile(fileName)
+    blk := &cfgBlock{kind: kind, id: id, start: -1}
+    for i, b := range f.blocks {
+        if b.kind == kind && b.id == after {
+            f.blocks = append(f.blocks[:i+1], append([]*cfgBlock{blk}, f.blocks[i+1:]...)...)
+            return blk
+        }
+    }
+    f.blocks = append(f.blocks, blk)
+    return blk
+}
+
+// format a new object definition the same way as an existing one (same attributes order and indentation)
+func (o *obj) formatLike(blk *cfgBlock, kind string, id string) {
+    f, i := o.findBlock(kind, id)
+    if f == nil {
+        return
+    }
+    like := f.blocks[i]
+    // new object definition formatted like an existing one
+    if like.start < 0 {
+        blk.likeRaw, blk.likeOrig = like.likeRaw, like.likeOrig
+        return
+    }
+    blk.likeRaw = strings.TrimLeft(f.raw[like.start:like.end], "\r\n")
+    blk.likeOrig = like.orig
+}
+
+// change the index of an object definition (e.g. host rename)
+func (o *obj) renameObjDef(kind string, oldID string, newID string) {
+    d := o.defsOf(kind)
+    (*d)[newID] = (*d)[oldID]
+    delete(*d, oldID)
+    if f, i := o.findBlock(kind, oldID); f != nil {
+        f.blocks[i].id = newID
+    }
+}
+
+// format a new object definition
+func formatBlock(kind string, d def) string {
+    objType, attrLen := getMaxAttr(objTypeOf(kind))
+    return formatObjDef(d, objType, attrLen)
+}
+
+// rewrite a changed object definition; unchanged lines (and comments) are kept as is
+func renderBlock(raw string, orig def, cur def) string {
+    lines := strings.Split(raw, "\n")
+    out := []string{}
+    indent, valueCol := "    ", 0
+    written := attrVal{}
+    closing := len(lines) - 1
+    for i, line := range lines {
+        m := reAttrLine.FindStringSubmatch(line)
+        // definition header, closing bracket, comments and blank lines
+        if i == closing || m == nil || strings.Contains(line, "define") && strings.HasSuffix(strings.TrimSpace(line), "{") {
+            if i == closing {
+                break
+            }
+            out = append(out, line)
+            continue
+        }
+        attr := m[2]
+        if valueCol == 0 {
+            indent, valueCol = m[1], len(m[2])+len(m[3])
+        }
+        if !cur.attrExist(attr) || written.Has(attr) {
+            continue
+        }
+        written.Add(attr)
+        if orig.attrExist(attr) && orig[attr].ToString() == cur[attr].ToString() {
+            out = append(out, line)
+            continue
+        }
+        out = append(out, m[1]+m[2]+m[3]+cur[attr].ToString())
+    }
+    // attributes added to the definition
+    newAttrs := []string{}
+    for attr := range cur {
+        if !written.Has(attr) {
+            newAttrs = append(newAttrs, attr)
+        }
+    }
+    sort.Strings(newAttrs)
+    for _, attr := range newAttrs {
+        gap := valueCol - len(attr)
+        if gap < 1 {
+            gap = 1
+        }
+        out = append(out, indent+attr+strings.Repeat(" ", gap)+cur[attr].ToString())
+    }
+    out = append(out, lines[closing])
+    return strings.Join(out, "\n")
+}
+
+// render config file content with the current object definitions
+func (f *cfgFile) render(o *obj) string {
+    var b strings.Builder
+    // separate new object definitions with a blank line
+    separate := func() {
+        if b.Len() == 0 {
+            return
+        }
+        if !strings.HasSuffix(b.String(), "\n") {
+            b.WriteString("\n")
+        }
+        b.WriteString("\n")
+    }
+    // blank lines around a deleted object definition are collapsed into one
+    collapse, pending := false, ""
+    write := func(s string) {
+        if collapse {
+            s = pending + s
+            if strings.TrimSpace(s) == "" {
+                pending = s
+                return
+            }
+            out := b.String()
+            allowed := 0
+            if out != "" {
+                allowed = 2 - (len(out) - len(strings.TrimRight(out, "\n")))
+            }
+            n := len(s) - len(strings.TrimLeft(s, "\n"))
+            if n > allowed {
+                s = s[n-allowed:]
+            }
+            collapse, pending = false, ""
+        }
+        b.WriteString(s)
+    }
+    pos := 0
+    for _, blk := range f.blocks {
+        cur, exist := (*o.defsOf(blk.kind))[blk.id]
+        if blk.start < 0 {
+            if exist && blk.likeRaw != "" {
+                separate()
+                b.WriteString(renderBlock(blk.likeRaw, blk.likeOrig, cur))
+            } else if exist {
+                separate()
+                b.WriteString(strings.TrimRight(formatBlock(blk.kind, cur), "\n"))
+            }
+            continue
+        }
+        write(f.raw[pos:blk.start])
+        pos = blk.end
+        switch {
+        case !exist:
+            // deleted object definition
+            collapse = true
+        case equalDef(blk.orig, cur):
+            write(f.raw[blk.start:blk.end])
+        default:
+            write(renderBlock(f.raw[blk.start:blk.end], blk.orig, cur))
+        }
+    }
+    write(f.raw[pos:])
+    data := b.String()
+    if data != "" && !strings.HasSuffix(data, "\n") {
+        data += "\n"
+    }
+    return data
+}
+
+// get config files that have been changed, [filename]new content
+func (o *obj) changedFiles() (names []string, data map[string]string) {
+    data = make(map[string]string)
+    for _, f := range o.files {
+        content := f.render(o)
+        if content != f.raw {
+            names = append(names, f.name)
+            data[f.name] = content
+        }
+    }
+    return names, data
+}
+
+// write changed config files, false if nothing has been written
+func (o *obj) WriteChanges(enabled map[string]interface{}, bflags attrVal) bool {
+    names, data := o.changedFiles()
+    return writeConfFiles(o.path, names, data, enabled, bflags)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "golang.org/x/crypto/ssh/terminal"
+)
+
+// object definition changed by the current run
+type objChange struct {
+    kind        string
+    id          string
+    state       string          // added, modified or deleted
+    file        *cfgFile        // config file of the object definition
+    block       *cfgBlock
+}
+
+// object definitions changed in memory compared with the config files, in file order
+func (o *obj) changes() (changes []objChange) {
+    for _, f := range o.files {
+        for _, b := range f.blocks {
+            cur, exist := (*o.defsOf(b.kind))[b.id]
+            switch {
+            case b.start < 0 && exist:
+                changes = append(changes, objChange{b.kind, b.id, "added", f, b})
+            case b.start < 0:
+            case !exist:
+                changes = append(changes, objChange{b.kind, b.id, "deleted", f, b})
+            case b.id != b.origID || !equalDef(b.orig, cur):
+                changes = append(changes, objChange{b.kind, b.id, "modified", f, b})
+            }
+        }
+    }
+    return changes
+}
+
+// drop a change from the current run, the object definition is restored as loaded from the file
+func (o *obj) revertChange(c objChange) {
+    d := o.defsOf(c.kind)
+    delete(*d, c.block.id)
+    if c.state == "added" {
+        return
+    }
+    c.block.id = c.block.origID
+    (*d)[c.block.id] = copyDef(c.block.orig)
+}
+
+// name of a changed object, objects without a name are described by their hosts and service
+func changeLabel(objDefs *obj, c objChange) string {
+    d, exist := (*objDefs.defsOf(c.kind))[c.id]
+    if !exist {
+        d = c.block.orig
+    }
+    if !strings.HasSuffix(c.kind, "dependency") && !strings.HasSuffix(c.kind, "escalation") {
+        return displayID(c.id)
+    }
+    label := []string{}
+    for _, attr := range []string{"host_name", "hostgroup_name", "service_description", "dependent_host_name", "dependent_hostgroup_name", "dependent_service_description"} {
+        if d.attrExist(attr) {
+            label = append(label, attr+"="+d[attr].ToString())
+        }
+    }
+    return strings.Join(label, " ")
+}
+
+// path of a config file relative to the nagios config directory
+func (o *obj) relPath(name string) string {
+    if rel, err := filepath.Rel(o.path, name); err == nil && !strings.HasPrefix(rel, "..") {
+        return rel
+    }
+    return name
+}
+
+// helper function to print the change set grouped by config file
+func printChangeSummary(s changeSummary, bflags attrVal) {
+    fmt.Println("Changes:")
+    total := make(map[string]int)
+    for _, f := range s.Files {
+        counts := map[string]int{"added": f.Added, "modified": f.Modified, "deleted": f.Deleted}
+        for state, n := range counts {
+            total[state] += n
+        }
+        name := f.File
+        if bflags.Has("color") {
+            name = Blue + name + RST
+        }
+        fmt.Printf("  %v: %v\n", name, formatCounts(counts))
+        for i, c := range s.Changes {
+            if c.File != f.File {
+                continue
+            }
+            state := fmt.Sprintf("%-8v", c.State)
+            if bflags.Has("color") {
+                state = changeColor(c.State) + state + RST
+            }
+            fmt.Printf("    %3v. %v [%v] %v\n", i+1, state, deleteCodeNames[c.Type], c.Name)
+        }
+    }
+    fmt.Printf("\nNum of changes: %v in %v file(s) (%v)\n\n", len(s.Changes), len(s.Files), formatCounts(total))
+}
+
+// helper function to format change counts e.g. 1 added, 2 deleted
+func formatCounts(counts map[string]int) string {
+    out := []string{}
+    for _, state := range []string{"added", "modified", "deleted"} {
+        if counts[state] > 0 {
+            out = append(out, fmt.Sprintf("%v %v", counts[state], state))
+        }
+    }
+    return strings.Join(out, ", ")
+}
+
+// color of a change state
+func changeColor(state string) string {
+    switch state {
+    case "added":
+        return Green
+    case "deleted":
+        return Red
+    }
+    return Yellow
+}
+
+// answers to confirmations are read from the terminal
+var confirmInput *bufio.Reader
+
+// ask a question until the first letter of the answer is one of choices, an empty answer or end of input is a no.
+// without a terminal the run is refused, --yes skips the confirmation
+func confirm(question string, choices string) string {
+    if confirmInput == nil {
+        if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+            err := errors.New("confirmation needs a terminal, use --yes to skip the confirmation")
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        confirmInput = bufio.NewReader(os.Stdin)
+    }
+    for {
+        fmt.Print(question)
+        answer, err := confirmInput.ReadString('\n'); if err != nil && answer == "" {
+            fmt.Println()
+            return "n"
+        }
+        answer = strings.ToLower(strings.TrimSpace(answer))
+        if answer == "" {
+            return "n"
+        }
+        if strings.Contains(choices, answer[:1]) {
+            return answer[:1]
+        }
+    }
+}
+
+// check if an object of a kind is defined by name, duplicated ids (name#2) included
+func isDefined(objDefs *obj, kind string, name string) bool {
+    for id := range *objDefs.defsOf(kind) {
+        if displayID(id) == name {
+            return true
+        }
+    }
+    return false
+}
+
+// references to objects the changes added, renamed or deleted that are not defined, [kind name]referencing objects
+func danglingRefs(objDefs *obj, changes []objChange) map[string][]string {
+    dangling := make(map[string][]string)
+    for _, c := range changes {
+        if idAttr(c.kind) == "" {
+            continue
+        }
+        for _, name := range []string{displayID(c.id), displayID(c.block.origID)} {
+            if _, seen := dangling[c.kind+" "+name]; seen || name == "" || isDefined(objDefs, c.kind, name) {
+                continue
+            }
+            if refs := findObjRefs(objDefs, c.kind, name); len(refs) > 0 {
+                dangling[c.kind+" "+name] = refs
+            }
+        }
+    }
+    return dangling
+}
+
+// ask which changes to apply: all, none or item by item. rejected changes are reverted
+func confirmChanges(objDefs *obj, changes []objChange, bflags attrVal) bool {
+    switch confirm("Apply the changes? [y]es, [n]o, [s]elect: ", "yns") {
+    case "n":
+        return false
+    case "y":
+        return true
+    }
+    before := danglingRefs(objDefs, changes)
+    approved := 0
+    for i, c := range changes {
+        if confirm(fmt.Sprintf("    %3v. %v [%v] %v? [y/n]: ", i+1, c.state, deleteCodeNames[c.kind], changeLabel(objDefs, c)), "yn") == "n" {
+            objDefs.revertChange(c)
+            continue
+        }
+        approved += 1
+    }
+    // a selection that splits a rename, a delete cascade or an add leaves references to objects that are not defined
+    after := danglingRefs(objDefs, changes)
+    keys := []string{}
+    for key := range after {
+        if _, exist := before[key]; !exist {
+            keys = append(keys, key)
+        }
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+        kindName := strings.SplitN(key, " ", 2)
+        err := errors.New("is not defined after the selection but still referenced by")
+        fmt.Println(&referenceError{err, kindName[0], kindName[1], after[key]})
+    }
+    if len(keys) > 0 {
+        fmt.Println("\nRefused: approve or reject the changes of a rename or delete together, no changes have been written")
+        exit(1)
+    }
+    fmt.Printf("\nNum of approved changes: %v of %v\n\n", approved, len(changes))
+    return approved > 0
+}
+
+// show the change set of the run, confirm it and write the approved changes
+// --dryrun shows the unified diff of the config files instead, --summary-out saves the change set as json
+// --plan-out saves the change set as a plan to be applied later with the apply command
+func (o *obj) commitChanges(enabled map[string]interface{}, bflags attrVal) {
+    summaryOut, _ := enabled["summary_out"].(string)
+    planOut, _ := enabled["plan_out"].(string)
+    changes := o.changes()
+    if len(changes) == 0 {
+        fmt.Println("No changes")
+        if summaryOut != "" {
+            writeChangeSummary(summaryOut, newChangeSummary(o, changes, bflags))
+        }
+        return
+    }
+    summary := newChangeSummary(o, changes, bflags)
+    printChangeSummary(summary, bflags)
+    checkGuards(summary.Changes, enabled, bflags)
+    if bflags.Has("dryrun") {
+        o.printDiff(bflags)
+    }
+    if summaryOut != "" && (bflags.Has("dryrun") || planOut != "") {
+        writeChangeSummary(summaryOut, summary)
+    }
+    if planOut != "" {
+        savePlan(o, planOut, summary)
+        fmt.Printf("\nPlan: saved to '%v', no changes have been written. Use 'apply %v' to apply it\n", planOut, planOut)
+        return
+    }
+    if bflags.Has("dryrun") {
+        fmt.Println("\nDryrun: no changes have been written")
+        return
+    }
+    if !bflags.Has("yes") && !confirmChanges(o, changes, bflags) {
+        fmt.Println("Canceled: no changes have been written")
+        return
+    }
+    // approved changes only, also used for the git commit message
+    summary = newChangeSummary(o, o.changes(), bflags)
+    enabled["change_summary"] = summary
+    if summaryOut != "" {
+        writeChangeSummary(summaryOut, summary)
+    }
+    if !o.WriteChanges(enabled, bflags) {
+        exit(1)
+    }
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+    "bufio"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestConfirm(t *testing.T) {
+    tests := []struct {
+        input   string
+        choices string
+        want    string
+    }{
+        {"y\n", "yn", "y"},
+        {"Yes\n", "yn", "y"},
+        {"select\n", "yns", "s"},
+        {"s\nno\n", "yn", "n"},
+        {"\n", "yns", "n"},
+        {"", "yn", "n"},
+        {"maybe", "yn", "n"},
+    }
+    defer func() { confirmInput = nil }()
+    for _, tt := range tests {
+        confirmInput = bufio.NewReader(strings.NewReader(tt.input))
+        if got := confirm("? ", tt.choices); got != tt.want {
+            t.Errorf("confirm() with input %q and choices %q = %q, want %q", tt.input, tt.choices, got, tt.want)
+        }
+    }
+}
+
+func TestDanglingRefsOfSplitRename(t *testing.T) {
+    _, root := testConfTree(t)
+    group := `define hostgroup{
+    hostgroup_name          web
+    members                 web01
+}
+`
+    if err := ioutil.WriteFile(filepath.Join(root, "objects", "hostgroups.cfg"), []byte(group), 0644); err != nil {
+        t.Fatal(err)
+    }
+    objDefs := loadNagiosData([]string{root}, ".cfg", []string{".git"})
+    renameHost(objDefs, "web01", "web99", attrVal{})
+    changes := objDefs.changes()
+    if got := danglingRefs(objDefs, changes); len(got) != 0 {
+        t.Fatalf("complete rename leaves dangling references: %v", got)
+    }
+    // reject the host definition, approve the hostgroup
+    for _, c := range changes {
+        if c.kind == "host" {
+            objDefs.revertChange(c)
+        }
+    }
+    got := danglingRefs(objDefs, changes)
+    if len(got) != 1 || len(got["host web99"]) != 1 {
+        t.Errorf("danglingRefs() = %v, want the hostgroup members reference to host web99", got)
+    }
+}
@@ -15,6 +15,16 @@ func (d def) attrExist(attrName string) bool {
     return false
 }
 
+// Get object ids of defs in sorted order
+func (d defs) sortedIDs() []string {
+    ids := make([]string, 0, len(d))
+    for id := range d {
+        ids = append(ids, id)
+    }
+    sort.Strings(ids)
+    return ids
+}
+
 // Check if offset already exist
 func (o offset) OffsetExist(id string) bool {
     if _, exist := o[id]; exist {
@@ -0,0 +1,424 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// short code names of the deleted references
+var deleteCodeNames = map[string]string{
+    "host":                 "HOST",
+    "hosttemplate":         "HOSTTMPL",
+    "service":              "SVC",
+    "servicetemplate":      "SVCTMPL",
+    "hostgroup":            "HGRP",
+    "servicegroup":         "SVCGRP",
+    "hostdependency":       "HOSTDEP",
+    "servicedependency":    "SVCDEP",
+    "hostescalation":       "HOSTESC",
+    "serviceescalation":    "SVCESC",
+    "contact":              "CONTACT",
+    "contacttemplate":      "CONTACTTMPL",
+    "contactgroup":         "CONTACTGRP",
+    "command":              "CMD",
+    "timeperiod":           "TIMEPERIOD",
+}
+
+// attributes of which at least one is needed for a definition to apply to anything
+func targetAttrs(kind string) [][]string {
+    switch kind {
+    case "service":
+        return [][]string{{"host_name", "hostgroup_name"}}
+    case "hostdependency":
+        return [][]string{{"host_name", "hostgroup_name"}, {"dependent_host_name", "dependent_hostgroup_name"}}
+    case "servicedependency":
+        return [][]string{{"host_name", "hostgroup_name", "servicegroup_name"}, {"dependent_host_name", "dependent_hostgroup_name", "dependent_servicegroup_name"}}
+    case "hostescalation":
+        return [][]string{{"host_name", "hostgroup_name"}}
+    case "serviceescalation":
+        return [][]string{{"host_name", "hostgroup_name", "servicegroup_name"}}
+    case "hostgroup":
+        return [][]string{{"members", "hostgroup_members"}}
+    case "servicegroup":
+        return [][]string{{"members", "servicegroup_members"}}
+    }
+    return nil
+}
+
+// remove the references of a value, the '+' additive prefix is moved to the new first item
+func removeValue(val attrVal, ref objRef, name string) (attrVal, attrVal) {
+    out, removed := attrVal{}, attrVal{}
+    for i := 0; i < len(val); i++ {
+        item := val[i]
+        switch ref.part {
+        case "host", "service":
+            // servicegroup members are host,service pairs
+            if i+1 < len(val) && (ref.part == "host" && item == name || ref.part == "service" && val[i+1] == name) {
+                removed.Add(item + "," + val[i+1])
+            } else if i+1 < len(val) {
+                out.Add(item, val[i+1])
+            }
+            i += 1
+            continue
+        case "command":
+            // command name followed by its !arguments
+            if strings.TrimSpace(strings.SplitN(item, "!", 2)[0]) == name {
+                removed.Add(item)
+                continue
+            }
+            out.Add(item)
+            continue
+        }
+        if strings.TrimLeft(item, "+!") == name {
+            removed.Add(strings.TrimPrefix(item, "+"))
+            continue
+        }
+        out.Add(item)
+    }
+    if len(val) > 0 && strings.HasPrefix(val[0], "+") && len(out) > 0 && !strings.HasPrefix(out[0], "+") {
+        out[0] = "+" + out[0]
+    }
+    return out, removed
+}
+
+// check if hosts or services join a group through their own hostgroups/servicegroups attribute
+func hasMembershipRefs(objDefs *obj, kind string, name string) bool {
+    for _, ref := range objRefs(kind) {
+        if ref.attr != kind+"s" {
+            continue
+        }
+        for _, d := range *objDefs.defsOf(ref.kind) {
+            if d.attrExist(ref.attr) && valueIndex(*d[ref.attr], name) >= 0 {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// check if a definition lost every target of one of its target attributes
+func isEmptyDef(objDefs *obj, kind string, id string, d def, emptied attrVal) bool {
+    for _, group := range targetAttrs(kind) {
+        lost, left := false, false
+        for _, attr := range group {
+            if emptied.Has(attr) {
+                lost = true
+            }
+            if d.attrExist(attr) {
+                left = true
+            }
+        }
+        if lost && !left {
+            // groups can still get members from the hostgroups/servicegroups attribute of their members
+            if (kind == "hostgroup" || kind == "servicegroup") && hasMembershipRefs(objDefs, kind, id) {
+                return false
+            }
+            return true
+        }
+    }
+    return false
+}
+
+// remove every reference to a deleted object, definitions left without targets are deleted as well
+// deleted hostgroups and servicegroups have their own references removed
+func removeObjRefs(objDefs *obj, kind string, name string, bflags attrVal) {
+    emptied := make(map[string]map[string]attrVal)
+    for _, ref := range objRefs(kind) {
+        d := objDefs.defsOf(ref.kind)
+        codeName := deleteCodeNames[ref.kind] + " " + strings.ToUpper(ref.attr)
+        for _, id := range d.sortedIDs() {
+            def := (*d)[id]
+            if !def.attrExist(ref.attr) {
+                continue
+            }
+            val, removed := removeValue(*def[ref.attr], ref, name)
+            if len(removed) == 0 {
+                continue
+            }
+            for _, v := range removed {
+                printDeletion(displayID(id), codeName, ref.attr, v, "val", bflags)
+            }
+            if len(val) > 0 {
+                def[ref.attr] = &val
+                continue
+            }
+            printDeletion(displayID(id), codeName, ref.attr, "", "attr", bflags)
+            delete(def, ref.attr)
+            if _, ok := emptied[ref.kind]; !ok {
+                emptied[ref.kind] = make(map[string]attrVal)
+            }
+            attrs := emptied[ref.kind][id]
+            attrs.Add(ref.attr)
+            emptied[ref.kind][id] = attrs
+        }
+    }
+    deleteEmptyDefs(objDefs, emptied, bflags)
+}
+
+// delete definitions left without targets, [kind][id] emptied attributes
+func deleteEmptyDefs(objDefs *obj, emptied map[string]map[string]attrVal, bflags attrVal) {
+    for _, k := range []string{"service", "hostgroup", "servicegroup", "hostdependency", "servicedependency", "hostescalation", "serviceescalation"} {
+        d := objDefs.defsOf(k)
+        for _, id := range d.sortedIDs() {
+            attrs, ok := emptied[k][id]
+            if !ok || !isEmptyDef(objDefs, k, id, (*d)[id], attrs) {
+                continue
+            }
+            printDeletion(displayID(id), deleteCodeNames[k], "", "", "def", bflags)
+            delete(*d, id)
+            if k == "hostgroup" || k == "servicegroup" {
+                removeObjRefs(objDefs, k, id, bflags)
+            }
+        }
+    }
+}
+
+// remove a host service pair from one side of a dependency/escalation, returns the emptied attribute
+// the host is removed when the side has no other service, otherwise the service is removed when the side has no other host
+func removeServicePair(objDefs *obj, kind string, id string, hostAttr string, descAttr string, groupAttrs []string, hostname string, desc string, bflags attrVal) string {
+    d := (*objDefs.defsOf(kind))[id]
+    if !d.attrExist(hostAttr) || !d.attrExist(descAttr) || valueIndex(*d[hostAttr], hostname) < 0 || valueIndex(*d[descAttr], desc) < 0 {
+        return ""
+    }
+    attr, val := hostAttr, hostname
+    if len(*d[descAttr]) > 1 {
+        grouped := false
+        for _, a := range groupAttrs {
+            if d.attrExist(a) {
+                grouped = true
+            }
+        }
+        if len(*d[hostAttr]) > 1 || grouped {
+            fmt.Printf("%vWarning%v: %v '%v' applies to other hosts and services, remove '%v %v' from it manually\n", Yellow, RST, kind, displayID(id), hostname, desc)
+            return ""
+        }
+        attr, val = descAttr, desc
+    }
+    codeName := deleteCodeNames[kind] + " " + strings.ToUpper(attr)
+    out, _ := removeValue(*d[attr], objRef{kind, attr, ""}, val)
+    printDeletion(displayID(id), codeName, attr, val, "val", bflags)
+    if len(out) > 0 {
+        d[attr] = &out
+        return ""
+    }
+    printDeletion(displayID(id), codeName, attr, "", "attr", bflags)
+    delete(d, attr)
+    return attr
+}
+
+// remove the references to the service of a host from servicegroups, dependencies and escalations
+func removeServiceRefs(objDefs *obj, hostname string, desc string, bflags attrVal) {
+    emptied := make(map[string]map[string]attrVal)
+    addEmptied := func(kind string, id string, attr string) {
+        if attr == "" {
+            return
+        }
+        if _, ok := emptied[kind]; !ok {
+            emptied[kind] = make(map[string]attrVal)
+        }
+        attrs := emptied[kind][id]
+        attrs.Add(attr)
+        emptied[kind][id] = attrs
+    }
+    // servicegroup members are host,service pairs
+    for _, id := range objDefs.servicegroupDefs.sortedIDs() {
+        sg := objDefs.servicegroupDefs[id]
+        if !sg.attrExist("members") {
+            continue
+        }
+        members, out := *sg["members"], attrVal{}
+        for i := 0; i+1 < len(members); i += 2 {
+            if members[i] == hostname && members[i+1] == desc {
+                printDeletion(id, "SVCGRP MEMBERS", "members", hostname+","+desc, "val", bflags)
+                continue
+            }
+            out.Add(members[i], members[i+1])
+        }
+        if len(out) == len(members) {
+            continue
+        }
+        if len(out) > 0 {
+            sg["members"] = &out
+            continue
+        }
+        printDeletion(id, "SVCGRP MEMBERS", "members", "", "attr", bflags)
+        delete(sg, "members")
+        addEmptied("servicegroup", id, "members")
+    }
+    for _, id := range objDefs.servicedependencyDefs.sortedIDs() {
+        addEmptied("servicedependency", id, removeServicePair(objDefs, "servicedependency", id, "host_name", "service_description", []string{"hostgroup_name", "servicegroup_name"}, hostname, desc, bflags))
+        // dependent service on the same host when dependent_host_name is not defined
+        hostAttr, groupAttrs := "dependent_host_name", []string{"dependent_hostgroup_name", "dependent_servicegroup_name"}
+        if d := objDefs.servicedependencyDefs[id]; !d.attrExist(hostAttr) && !d.attrExist(groupAttrs[0]) && !d.attrExist(groupAttrs[1]) {
+            hostAttr, groupAttrs = "host_name", []string{"hostgroup_name", "servicegroup_name"}
+        }
+        addEmptied("servicedependency", id, removeServicePair(objDefs, "servicedependency", id, hostAttr, "dependent_service_description", groupAttrs, hostname, desc, bflags))
+    }
+    for _, id := range objDefs.serviceescalationDefs.sortedIDs() {
+        addEmptied("serviceescalation", id, removeServicePair(objDefs, "serviceescalation", id, "host_name", "service_description", []string{"hostgroup_name", "servicegroup_name"}, hostname, desc, bflags))
+    }
+    deleteEmptyDefs(objDefs, emptied, bflags)
+}
+
+// delete one service from one host, definitions shared with other hosts keep the service for them
+// the host is removed from host_name, or excluded with '!host' when it gets the service through a hostgroup or template
+func deleteHostService(objDefs *obj, hostname string, desc string, bflags attrVal) bool {
+    dups := findDuplicateService(objDefs, hostname, desc)
+    if len(dups) == 0 {
+        return false
+    }
+    ids := []string{}
+    for id := range dups {
+        ids = append(ids, id)
+    }
+    sort.Strings(ids)
+    for _, id := range ids {
+        d := objDefs.serviceDefs[id]
+        if dups[id] == "host_name" && d.attrExist("host_name") && valueIndex(*d["host_name"], hostname) >= 0 {
+            if len(*d["host_name"]) == 1 && !d.attrExist("hostgroup_name") {
+                printDeletion(displayID(id), "SVC", "", "", "def", bflags)
+                delete(objDefs.serviceDefs, id)
+                continue
+            }
+            changeAttr(objDefs, "service", id, "remove", "host_name", hostname, bflags)
+            // the host may still get the service through the hostgroup_name of the same definition
+            if _, still := findDuplicateService(objDefs, hostname, desc)[id]; !still {
+                continue
+            }
+        }
+        changeAttr(objDefs, "service", id, "append", "host_name", "!"+hostname, bflags)
+    }
+    if left := findDuplicateService(objDefs, hostname, desc); len(left) > 0 {
+        fmt.Printf("%vWarning%v: host '%v' still gets service '%v'\n", Yellow, RST, hostname, desc)
+    }
+    removeServiceRefs(objDefs, hostname, desc, bflags)
+    return true
+}
+
+// find every reference to an object
+func findObjRefs(objDefs *obj, kind string, name string) (refs []string) {
+    for _, ref := range objRefs(kind) {
+        d := objDefs.defsOf(ref.kind)
+        for _, id := range d.sortedIDs() {
+            def := (*d)[id]
+            if !def.attrExist(ref.attr) {
+                continue
+            }
+            if _, removed := removeValue(*def[ref.attr], ref, name); len(removed) > 0 {
+                refs = append(refs, fmt.Sprintf("%v of %v '%v'", ref.attr, ref.kind, displayID(id)))
+            }
+        }
+    }
+    return refs
+}
+
+// hosts and services that miss required attributes, [kind id]missing attributes
+func invalidObjs(objDefs *obj) map[string][]string {
+    invalid := make(map[string][]string)
+    for _, kind := range []string{"host", "service"} {
+        required := requiredHostAttr
+        if kind == "service" {
+            required = requiredServiceAttr
+        }
+        for id, d := range *objDefs.defsOf(kind) {
+            resolved := buildUseTree(objDefs.templateDefs(kind), id, kind, d, attrVal{}).resolve()
+            if missing := missingAttr(resolved, required); len(missing) > 0 {
+                invalid[kind+" "+id] = missing
+            }
+        }
+    }
+    return invalid
+}
+
+// delete an object definition, objects that still reference it are refused unless cascade is set
+// with cascade the objects using a template are detached from it and the other references are removed
+func deleteObj(objDefs *obj, kind string, name string, cascade bool, bflags attrVal) bool {
+    d := objDefs.defsOf(kind)
+    if _, exist := (*d)[name]; !exist {
+        err := fmt.Errorf("%v not found", kind)
+        fmt.Println(&NotFoundError{err, "Warn", name})
+        return false
+    }
+    if refs := findObjRefs(objDefs, kind, name); len(refs) > 0 && !cascade {
+        err := errors.New("is still referenced, use --cascade to detach the references")
+        fmt.Println(&referenceError{err, kind, name, refs})
+        return false
+    }
+    if strings.HasSuffix(kind, "template") {
+        for _, k := range []string{objTypeOf(kind), kind} {
+            users := objDefs.defsOf(k)
+            for _, id := range users.sortedIDs() {
+                u := (*users)[id]
+                if !u.attrExist("use") || valueIndex(*u["use"], name) < 0 {
+                    continue
+                }
+                before := copyDef(u)
+                detachUse(objDefs, k, id, name)
+                printModification(k, id, before, u, bflags)
+            }
+        }
+    }
+    printDeletion(name, deleteCodeNames[kind], "", "", "def", bflags)
+    delete(*d, name)
+    removeObjRefs(objDefs, kind, name, bflags)
+    return true
+}
+
+// delete objects of a kind, a run that leaves hosts or services without required attributes (nagios would refuse
+// the config) is refused unless --force is set
+func deleteObjects(objDefs *obj, kind string, names []string, cascade bool, bflags attrVal) int {
+    switch kind {
+    case "hostgroup", "servicegroup", "hosttemplate", "servicetemplate", "contacttemplate", "contact", "contactgroup", "command", "timeperiod":
+    default:
+        err := fmt.Errorf("unsupported object type '%v'", kind)
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    invalid := invalidObjs(objDefs)
+    n := 0
+    for _, name := range names {
+        if deleteObj(objDefs, kind, name, cascade, bflags) {
+            n += 1
+        }
+    }
+    after := invalidObjs(objDefs)
+    ids := []string{}
+    for id := range after {
+        if _, ok := invalid[id]; !ok {
+            ids = append(ids, id)
+        }
+    }
+    sort.Strings(ids)
+    for _, id := range ids {
+        if bflags.Has("force") {
+            fmt.Printf("%vWarning%v: %v is missing required attributes: %v, forced\n", Yellow, RST, displayID(id), strings.Join(after[id], ", "))
+            continue
+        }
+        kindID := strings.SplitN(id, " ", 2)
+        err := errors.New("would be left without required attributes")
+        fmt.Println(&missingAttributeError{err, kindID[0], displayID(kindID[1]), after[id]})
+    }
+    if len(ids) > 0 && !bflags.Has("force") {
+        fmt.Println("\nRefused: nagios would not load the config, no changes have been written. Use --force to delete anyway")
+        exit(1)
+    }
+    return n
+}
+
+// delete objects of other types than host by name
+func deleteObjCmd(objDefs *obj, pos []string, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    if len(pos) < 2 {
+        err := errors.New("expected 'delete <object type> <name>...'")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    cascade := false
+    if cval, ok := visited["cascade"]; ok {
+        cascade = cval.(bool)
+    }
+    n := deleteObjects(objDefs, pos[0], pos[1:], cascade, bflags)
+    fmt.Printf("\nNum of deleted objects: %v\n\n", n)
+    objDefs.commitChanges(enabled, bflags)
+}
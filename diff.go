@@ -0,0 +1,277 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "strings"
+)
+
+// number of unchanged lines shown around a change
+const diffContext = 3
+
+// line of a diff, kind is ' ' (unchanged), '-' (removed) or '+' (added)
+type diffOp struct {
+    kind        byte
+    line        string
+}
+
+// split content into lines, a trailing newline does not start a new line
+func splitLines(s string) []string {
+    if s == "" {
+        return nil
+    }
+    return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lines of a file content for the diff, a last line without newline keeps a "\n" suffix
+// so it differs from the same line with a newline
+func diffInput(s string) []string {
+    lines := splitLines(s)
+    if len(lines) > 0 && !strings.HasSuffix(s, "\n") {
+        lines[len(lines)-1] += "\n"
+    }
+    return lines
+}
+
+// shortest edit script between two lines slices (myers diff)
+func diffLines(a []string, b []string) []diffOp {
+    // common prefix and suffix are kept out of the search
+    pre := 0
+    for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+        pre++
+    }
+    suf := 0
+    for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+        suf++
+    }
+    ops := []diffOp{}
+    for _, l := range a[:pre] {
+        ops = append(ops, diffOp{' ', l})
+    }
+    ops = append(ops, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+    for _, l := range a[len(a)-suf:] {
+        ops = append(ops, diffOp{' ', l})
+    }
+    return ops
+}
+
+// myers algorithm, trace keeps the furthest reaching paths of every edit distance for the backtrack
+func myers(a []string, b []string) []diffOp {
+    n, m := len(a), len(b)
+    if n+m == 0 {
+        return nil
+    }
+    max := n + m
+    offset := max + 1
+    v := make([]int, 2*max+2)
+    trace := [][]int{}
+    done := false
+    for d := 0; d <= max && !done; d++ {
+        trace = append(trace, append([]int{}, v...))
+        for k := -d; k <= d; k += 2 {
+            x := 0
+            if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+                x = v[offset+k+1]
+            } else {
+                x = v[offset+k-1] + 1
+            }
+            y := x - k
+            for x < n && y < m && a[x] == b[y] {
+                x++
+                y++
+            }
+            v[offset+k] = x
+            if x >= n && y >= m {
+                done = true
+                break
+            }
+        }
+    }
+    // walk back from the end, ops are collected in reverse order
+    rev := []diffOp{}
+    x, y := n, m
+    for d := len(trace) - 1; d >= 0; d-- {
+        v := trace[d]
+        k := x - y
+        prevK := k - 1
+        if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+            prevK = k + 1
+        }
+        prevX := v[offset+prevK]
+        prevY := prevX - prevK
+        for x > prevX && y > prevY {
+            rev = append(rev, diffOp{' ', a[x-1]})
+            x--
+            y--
+        }
+        if d == 0 {
+            break
+        }
+        if x == prevX {
+            rev = append(rev, diffOp{'+', b[y-1]})
+            y--
+        } else {
+            rev = append(rev, diffOp{'-', a[x-1]})
+            x--
+        }
+    }
+    ops := make([]diffOp, len(rev))
+    for i, op := range rev {
+        ops[len(rev)-1-i] = op
+    }
+    return ops
+}
+
+// unified diff of two file contents, empty if they are the same
+func unifiedDiff(fromName string, toName string, from string, to string, bflags attrVal) string {
+    ops := diffLines(diffInput(from), diffInput(to))
+    // line numbers before every op
+    aPos, bPos := make([]int, len(ops)+1), make([]int, len(ops)+1)
+    changed := []int{}
+    for i, op := range ops {
+        aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+        if op.kind != '+' {
+            aPos[i+1]++
+        }
+        if op.kind != '-' {
+            bPos[i+1]++
+        }
+        if op.kind != ' ' {
+            changed = append(changed, i)
+        }
+    }
+    if len(changed) == 0 {
+        return ""
+    }
+    color := func(c string, s string) string {
+        if bflags.Has("color") {
+            return c + s + RST
+        }
+        return s
+    }
+    var out strings.Builder
+    out.WriteString(color(Red, "--- "+fromName) + "\n")
+    out.WriteString(color(Green, "+++ "+toName) + "\n")
+    for i := 0; i < len(changed); {
+        start := changed[i] - diffContext
+        if start < 0 {
+            start = 0
+        }
+        // changes closer than two contexts share a hunk
+        j := i
+        for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*diffContext+1 {
+            j++
+        }
+        end := changed[j] + diffContext + 1
+        if end > len(ops) {
+            end = len(ops)
+        }
+        aLen, bLen := aPos[end]-aPos[start], bPos[end]-bPos[start]
+        aStart, bStart := aPos[start]+1, bPos[start]+1
+        if aLen == 0 {
+            aStart--
+        }
+        if bLen == 0 {
+            bStart--
+        }
+        out.WriteString(color(Blue, fmt.Sprintf("@@ -%v,%v +%v,%v @@", aStart, aLen, bStart, bLen)) + "\n")
+        for _, op := range ops[start:end] {
+            line := strings.TrimSuffix(op.line, "\n")
+            switch op.kind {
+            case '-':
+                out.WriteString(color(Red, "-"+line) + "\n")
+            case '+':
+                out.WriteString(color(Green, "+"+line) + "\n")
+            default:
+                out.WriteString(" " + line + "\n")
+            }
+            // right after the last line of the side that has no newline at the end
+            if line != op.line {
+                out.WriteString("\\ No newline at end of file\n")
+            }
+        }
+        i = j + 1
+    }
+    return out.String()
+}
+
+// print the unified diff of every changed config file
+func (o *obj) printDiff(bflags attrVal) {
+    names, data := o.changedFiles()
+    for _, name := range names {
+        f := o.confFile(name)
+        fromName := "a/" + o.relPath(name)
+        if f.raw == "" && !isFileExist(name) {
+            fromName = "/dev/null"
+        }
+        fmt.Print(unifiedDiff(fromName, "b/"+o.relPath(name), f.raw, data[name], bflags))
+    }
+}
+
+// machine-readable summary of a change set
+type changeSummary struct {
+    Command     string              `json:"command"`
+    Dryrun      bool                `json:"dryrun"`
+    Files       []fileSummary       `json:"files"`
+    Changes     []objSummary        `json:"changes"`
+}
+
+// changes of one config file
+type fileSummary struct {
+    File        string              `json:"file"`
+    New         bool                `json:"new"`
+    Added       int                 `json:"added"`
+    Modified    int                 `json:"modified"`
+    Deleted     int                 `json:"deleted"`
+}
+
+// change of one object definition
+type objSummary struct {
+    Type        string              `json:"type"`
+    Name        string              `json:"name"`
+    State       string              `json:"state"`
+    File        string              `json:"file"`
+    OrigName    string              `json:"orig_name,omitempty"`    // name before a rename
+}
+
+// build the summary of a change set
+func newChangeSummary(o *obj, changes []objChange, bflags attrVal) changeSummary {
+    s := changeSummary{Command: strings.Join(os.Args[1:2], ""), Dryrun: bflags.Has("dryrun"), Files: []fileSummary{}, Changes: []objSummary{}}
+    index := make(map[*cfgFile]int)
+    for _, c := range changes {
+        i, ok := index[c.file]
+        if !ok {
+            i = len(s.Files)
+            index[c.file] = i
+            s.Files = append(s.Files, fileSummary{File: o.relPath(c.file.name), New: !isFileExist(c.file.name)})
+        }
+        switch c.state {
+        case "added":
+            s.Files[i].Added += 1
+        case "modified":
+            s.Files[i].Modified += 1
+        case "deleted":
+            s.Files[i].Deleted += 1
+        }
+        orig := ""
+        if c.block.origID != c.block.id && c.state == "modified" {
+            orig = displayID(c.block.origID)
+        }
+        s.Changes = append(s.Changes, objSummary{c.kind, changeLabel(o, c), c.state, o.relPath(c.file.name), orig})
+    }
+    return s
+}
+
+// write the change set summary as json
+func writeChangeSummary(fileName string, s changeSummary) {
+    jdata, err := json.MarshalIndent(s, "", "  "); if err != nil {
+        fmt.Println(err)
+        exit(1)
+    }
+    if err := ioutil.WriteFile(fileName, append(jdata, '\n'), 0644); err != nil {
+        fmt.Println(err)
+        exit(1)
+    }
+}
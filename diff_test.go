@@ -0,0 +1,142 @@
+package main
+
+import (
+    "strconv"
+    "strings"
+    "testing"
+)
+
+// numbered lines from 1 to n, without newline at the end
+func testNumLines(n int, replace map[string]string) string {
+    lines := []string{}
+    for i := 1; i <= n; i++ {
+        l := strconv.Itoa(i)
+        if r, ok := replace[l]; ok {
+            l = r
+        }
+        lines = append(lines, l)
+    }
+    return strings.Join(lines, "\n")
+}
+
+func TestUnifiedDiff(t *testing.T) {
+    tests := []struct {
+        name    string
+        from    string
+        to      string
+        want    string
+    }{
+        {"same content", "x\ny\n", "x\ny\n", ""},
+        {"both empty", "", "", ""},
+        {"newline removed", "x\ny\n", "x\ny", `--- a
++++ b
+@@ -1,2 +1,2 @@
+ x
+-y
++y
+\ No newline at end of file
+`},
+        {"newline added", "x\ny", "x\ny\n", `--- a
++++ b
+@@ -1,2 +1,2 @@
+ x
+-y
+\ No newline at end of file
++y
+`},
+        {"no newline on both sides", "a\nb\nc", "a\nB\nc", `--- a
++++ b
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+\ No newline at end of file
+`},
+        {"no newline outside of the hunk", testNumLines(10, nil), testNumLines(10, map[string]string{"2": "two"}), `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+`},
+        {"new file", "", "a\nb\n", `--- a
++++ b
+@@ -0,0 +1,2 @@
++a
++b
+`},
+        {"removed content", "a\nb\n", "", `--- a
++++ b
+@@ -1,2 +0,0 @@
+-a
+-b
+`},
+        {"separate hunks", testNumLines(20, nil), testNumLines(20, map[string]string{"2": "two", "19": "nineteen"}), `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -16,5 +16,5 @@
+ 16
+ 17
+ 18
+-19
++nineteen
+ 20
+\ No newline at end of file
+`},
+        {"merged hunk", testNumLines(10, nil) + "\n", testNumLines(10, map[string]string{"2": "two", "9": "nine"}) + "\n", `--- a
++++ b
+@@ -1,10 +1,10 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+ 6
+ 7
+ 8
+-9
++nine
+ 10
+`},
+    }
+    for _, tt := range tests {
+        got := unifiedDiff("a", "b", tt.from, tt.to, attrVal{})
+        if got != tt.want {
+            t.Errorf("%v: unifiedDiff() =\n%v\nwant\n%v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestDiffLines(t *testing.T) {
+    tests := []struct {
+        a       []string
+        b       []string
+        want    string
+    }{
+        {nil, nil, ""},
+        {[]string{"x"}, []string{"x"}, " x"},
+        {[]string{"a", "b", "c"}, []string{"a", "c"}, " a-b c"},
+        {[]string{"a", "c"}, []string{"a", "b", "c"}, " a+b c"},
+        {[]string{"a", "b"}, []string{"c", "d"}, "-a-b+c+d"},
+    }
+    for _, tt := range tests {
+        got := ""
+        for _, op := range diffLines(tt.a, tt.b) {
+            got += string(op.kind) + op.line
+        }
+        if got != tt.want {
+            t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+        }
+    }
+}
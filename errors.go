@@ -2,6 +2,7 @@ package main
 
 import (
     "fmt"
+    "strings"
 )
 
 // parsing error
@@ -34,6 +35,59 @@ type NotFoundError struct {
     value string        // object value 
 }
 
+// object already exist error
+type duplicateObjectError struct {
+    err error           // what happen
+    objType string      // Nagios object type (host,service,...)
+    value string        // object name
+}
+
+// object still referenced error
+type referenceError struct {
+    err error           // what happen
+    objType string      // Nagios object type (host,service,...)
+    value string        // object name
+    refs []string       // objects that reference the object
+}
+
+// protected object or deletion limit error
+type guardError struct {
+    err error           // what happen
+    value string        // object name or limit
+}
+
+// config file changed since a plan was made error
+type driftError struct {
+    err error           // what happen
+    value string        // config file
+}
+
+// config directory lock error
+type lockError struct {
+    err error           // what happen
+    value string        // lock file
+}
+
+// config verification error
+type verifyError struct {
+    err error           // what happen
+    value string        // verification command
+}
+
+// git command error
+type gitError struct {
+    err error           // what happen
+    value string        // git command
+}
+
+// missing required attribute error
+type missingAttributeError struct {
+    err error           // what happen
+    objType string      // Nagios object type (host,service,...)
+    value string        // object name
+    attrs []string      // missing attributes
+}
+
 // unknown object error format
 func (e *unknownObjectError) Error() string {
     fDef := formatAttr(e.oDef)
@@ -60,3 +114,43 @@ func (e *NotFoundError) Error() string {
 func (e *parsingError) Error() string {
     return fmt.Sprintf("ArgsParsing: %vError%v: %v", Red, RST, e.err)
 }
+
+// object already exist error format
+func (e *duplicateObjectError) Error() string {
+    return fmt.Sprintf("DuplicateObject: %vError%v: %v %v '%v'", Red, RST, e.err, e.objType, e.value)
+}
+
+// missing required attribute error format
+func (e *missingAttributeError) Error() string {
+    return fmt.Sprintf("MissingAttribute: %vError%v: %v %v '%v': %v", Red, RST, e.objType, e.err, e.value, strings.Join(e.attrs, ", "))
+}
+
+// object still referenced error format
+func (e *referenceError) Error() string {
+    return fmt.Sprintf("Referenced: %vError%v: %v '%v' %v\n    %v", Red, RST, e.objType, e.value, e.err, strings.Join(e.refs, "\n    "))
+}
+
+// protected object or deletion limit error format
+func (e *guardError) Error() string {
+    return fmt.Sprintf("Guard: %vError%v: '%v' %v", Red, RST, e.value, e.err)
+}
+
+// config drifted from a plan error format
+func (e *driftError) Error() string {
+    return fmt.Sprintf("Drift: %vError%v: '%v' %v", Red, RST, e.value, e.err)
+}
+
+// config directory lock error format
+func (e *lockError) Error() string {
+    return fmt.Sprintf("Lock: %vError%v: '%v' %v", Red, RST, e.value, e.err)
+}
+
+// git command error format
+func (e *gitError) Error() string {
+    return fmt.Sprintf("Git: %vError%v: '%v' %v", Red, RST, e.value, e.err)
+}
+
+// config verification error format
+func (e *verifyError) Error() string {
+    return fmt.Sprintf("Verify: %vError%v: '%v' %v", Red, RST, e.value, e.err)
+}
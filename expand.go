@@ -0,0 +1,235 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// nagios macro e.g. $HOSTADDRESS$, '$$' is an escaped '$'
+var reMacro = regexp.MustCompile(`\$([A-Za-z0-9_]*)\$`)
+
+// check command expanded into the final command line
+type expandedCommand struct {
+    hostName        string              // host the check belongs to
+    serviceDesc     string              // service description, empty for host check
+    checkCommand    string              // check_command as declared in object/template
+    checkSource     string              // object/template that set check_command
+    commandName     string              // command_name part of check_command
+    args            []string            // !ARGn arguments of check_command
+    commandLine     string              // command_line of the command definition
+    expanded        string              // command line after macro substitution
+    macros          map[string]string   // macros used during substitution
+    unresolved      attrVal             // macros that could not be resolved
+}
+
+// build the macros of a host (standard host macros and _HOST custom variables)
+func hostMacros(hostname string, host def, macros map[string]string) {
+    macros["HOSTNAME"] = hostname
+    macros["HOSTADDRESS"] = hostname
+    macros["HOSTALIAS"] = hostname
+    macros["HOSTDISPLAYNAME"] = hostname
+    if host.attrExist("address") {
+        macros["HOSTADDRESS"] = host["address"].ToString()
+    }
+    if host.attrExist("alias") {
+        macros["HOSTALIAS"] = host["alias"].ToString()
+    }
+    if host.attrExist("display_name") {
+        macros["HOSTDISPLAYNAME"] = host["display_name"].ToString()
+    }
+    for attr, val := range host {
+        if strings.HasPrefix(attr, "_") {
+            macros["_HOST"+strings.ToUpper(attr[1:])] = val.ToString()
+        }
+    }
+}
+
+// build the macros of a service (standard service macros and _SERVICE custom variables)
+func serviceMacros(svc def, macros map[string]string) {
+    macros["SERVICEDESC"] = svc["service_description"].ToString()
+    macros["SERVICEDISPLAYNAME"] = macros["SERVICEDESC"]
+    if svc.attrExist("display_name") {
+        macros["SERVICEDISPLAYNAME"] = svc["display_name"].ToString()
+    }
+    for attr, val := range svc {
+        if strings.HasPrefix(attr, "_") {
+            macros["_SERVICE"+strings.ToUpper(attr[1:])] = val.ToString()
+        }
+    }
+}
+
+// substitute macros in a string, unknown macros are kept as is and returned
+func substituteMacros(s string, macros map[string]string, numArgs int) (string, attrVal) {
+    unresolved := attrVal{}
+    expanded := reMacro.ReplaceAllStringFunc(s, func(m string) string {
+        name := m[1 : len(m)-1]
+        if name == "" {
+            return "$"
+        }
+        if val, ok := macros[name]; ok {
+            return val
+        }
+        // nagios replace missing $ARGn$ with an empty string
+        if strings.HasPrefix(name, "ARG") {
+            if n, err := strconv.Atoi(name[3:]); err == nil && n > numArgs {
+                unresolved.Add(m + " (missing argument)")
+                return ""
+            }
+        }
+        if !unresolved.Has(m) {
+            unresolved.Add(m)
+        }
+        return m
+    })
+    return expanded, unresolved
+}
+
+// expand check_command of a host (svcID == "") or a host service into the final command line
+func expandCheckCommand(objDefs *obj, hostname string, svcID string) (*expandedCommand, error) {
+    cmd := &expandedCommand{hostName: hostname, macros: make(map[string]string)}
+    hostTree := buildUseTree(&objDefs.hostTempDefs, hostname, "host", objDefs.hostDefs[hostname], attrVal{})
+    host := hostTree.resolve()
+    for k, v := range objDefs.resourceMacros {
+        cmd.macros[k] = v
+    }
+    hostMacros(hostname, host, cmd.macros)
+    tree := hostTree
+    resolved := host
+    if svcID != "" {
+        tree = buildUseTree(&objDefs.serviceTempDefs, svcID, "service", objDefs.serviceDefs[svcID], attrVal{})
+        resolved = tree.resolve()
+        cmd.serviceDesc = resolved["service_description"].ToString()
+        serviceMacros(resolved, cmd.macros)
+    }
+    if !resolved.attrExist("check_command") {
+        return cmd, errors.New("check_command is not defined in object or its templates")
+    }
+    cmd.checkCommand = resolved["check_command"].ToString()
+    cmd.checkSource = tree.attrSources()["check_command"][0].name
+    parts := strings.Split(cmd.checkCommand, "!")
+    cmd.commandName = strings.TrimSpace(parts[0])
+    cmd.args = parts[1:]
+    command, ok := objDefs.commandDefs[cmd.commandName]
+    if !ok {
+        return cmd, fmt.Errorf("command '%v' is not defined", cmd.commandName)
+    }
+    cmd.commandLine = command["command_line"].ToString()
+    // macros in the arguments are expanded before they are passed to the command line
+    for i, arg := range cmd.args {
+        expandedArg, unresolved := substituteMacros(arg, cmd.macros, len(cmd.args))
+        cmd.macros["ARG"+strconv.Itoa(i+1)] = expandedArg
+        for _, m := range unresolved {
+            if !cmd.unresolved.Has(m) {
+                cmd.unresolved.Add(m)
+            }
+        }
+    }
+    expanded, unresolved := substituteMacros(cmd.commandLine, cmd.macros, len(cmd.args))
+    cmd.expanded = expanded
+    for _, m := range unresolved {
+        if !cmd.unresolved.Has(m) {
+            cmd.unresolved.Add(m)
+        }
+    }
+    return cmd, nil
+}
+
+// print the expanded command
+func (c *expandedCommand) print(bflags attrVal) {
+    title := c.hostName
+    if c.serviceDesc != "" {
+        title = fmt.Sprintf("%v / %v", c.hostName, c.serviceDesc)
+    }
+    // macros used by the command line and its arguments (arguments are printed on their own)
+    used := attrVal{}
+    for _, m := range reMacro.FindAllStringSubmatch(c.commandLine+"!"+strings.Join(c.args, "!"), -1) {
+        if _, ok := c.macros[m[1]]; ok && !strings.HasPrefix(m[1], "ARG") && !used.Has("$"+m[1]+"$") {
+            used.Add("$" + m[1] + "$")
+        }
+    }
+    sort.Strings(used)
+    width := MaxLen(&used) + 2
+    fmt.Printf("%v%v%v\n", Green, title, RST)
+    fmt.Printf("\t%-*v%v (from %v)\n", width, "check_command", c.checkCommand, c.checkSource)
+    fmt.Printf("\t%-*v%v\n", width, "command_name", c.commandName)
+    for i, arg := range c.args {
+        fmt.Printf("\t%-*v%v\n", width, fmt.Sprintf("$ARG%v$", i+1), arg)
+    }
+    fmt.Printf("\t%-*v%v\n", width, "command_line", c.commandLine)
+    if bflags.Has("verbose") {
+        for _, m := range used {
+            fmt.Printf("\t%-*v%v\n", width, m, c.macros[strings.Trim(m, "$")])
+        }
+    }
+    fmt.Printf("\n\t%v\n\n", c.expanded)
+    for _, m := range c.unresolved {
+        if bflags.Has("color") {
+            fmt.Printf("\t%vUnresolved%v: %v\n", Yellow, RST, m)
+        } else {
+            fmt.Printf("\tUnresolved: %v\n", m)
+        }
+    }
+    if len(c.unresolved) > 0 {
+        fmt.Println()
+    }
+}
+
+// expand check_command into the command line nagios will run
+func expandCmd(objDefs *obj, visited map[string]interface{}, bflags attrVal) {
+    hval, sh := visited["host"]
+    sval, ss := visited["service"]
+    if !sh {
+        err := errors.New("--host option is required")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    // additional resource file ($USERn$ macros) outside of the nagios configs directory
+    if rval, ok := visited["resource"]; ok {
+        for _, f := range rval.([]string) {
+            data, err := ioutil.ReadFile(f); if err != nil {
+                fmt.Println(&parsingError{err})
+                exit(1)
+            }
+            parseResourceMacros(string(data), objDefs.resourceMacros)
+        }
+    }
+    for _, h := range hval.([]string) {
+        if _, ok := objDefs.hostDefs[h]; !ok {
+            err := errors.New("host not found")
+            fmt.Println(&NotFoundError{err, "Warn", h})
+            continue
+        }
+        svcIDs := []string{""}
+        if ss {
+            svcIDs = []string{}
+            for _, desc := range sval.([]string) {
+                ids := findServicesByDesc(objDefs, desc, h)
+                if len(ids) == 0 {
+                    err := errors.New("service not found")
+                    fmt.Println(&NotFoundError{err, "Warn", h + " " + desc})
+                }
+                if len(ids) > 1 {
+                    fmt.Printf("%vWarning%v: host '%v' has %v definitions of service '%v'\n", Yellow, RST, h, len(ids), desc)
+                }
+                svcIDs = append(svcIDs, ids...)
+            }
+        }
+        for _, id := range svcIDs {
+            cmd, err := expandCheckCommand(objDefs, h, id)
+            if err != nil {
+                name := h
+                if cmd.serviceDesc != "" {
+                    name = h + " " + cmd.serviceDesc
+                }
+                fmt.Println(&NotFoundError{err, "Warn", name})
+                continue
+            }
+            cmd.print(bflags)
+        }
+    }
+}
@@ -52,7 +52,7 @@ func formatObjDef (od def, objType string, maxAttrLen int) string {
     objDefFormat := ""
     attrNames := od.sortAttrNames()                                                         // sort map keys
     for _,attrName := range attrNames { 
-        attrValue := od[attrName].joinAttrVal()
+        attrValue := od[attrName].ToString()                                                // keep values order (e.g. 'use' precedence)
         objDefFormat += fmt.Sprintf("\t%*v% v\n",-(maxAttrLen+4), attrName,attrValue)         //formated attr
     }
     return objType+"{\n"+objDefFormat+"}\n"
@@ -82,18 +82,21 @@ func getMaxAttr(s string) (string, int) {
     case "contactgroup":
         maxAttrLength = maxCGrpAttrLen
         objType       = "define contactgroup"
-    case "hostdpendency":
+    case "hostdependency":
         maxAttrLength = maxHostDpndAttrLen
         objType       = "define hostdependency"
-    case "servicedpendency":
+    case "servicedependency":
         maxAttrLength = maxSvcDpndlAttrLen
         objType       = "define servicedependency"
     case "serviceescalation":
         maxAttrLength = maxSvcEsclAttrLen
         objType       = "define serviceescalation"
-    case "Hostescalation":
+    case "hostescalation":
         maxAttrLength = maxHostEsclAttrLen
         objType       = "define hostescalation"
+    case "command":
+        maxAttrLength = maxCmdAttrLen
+        objType       = "define command"
     default:
         //warning
         maxAttrLength = 30
@@ -135,6 +138,17 @@ func (a *attrVal) printAttr() {
     fmt.Println()
 
 }
+// strip unique suffix from object ids before printing them
+func (a attrVal) displayIDs() attrVal {
+    ids := attrVal{}
+    for _, v := range a {
+        if !ids.Has(displayID(v)) {
+            ids.Add(displayID(v))
+        }
+    }
+    return ids
+}
+
 // get max length of items in slice
 func MaxLen(a *attrVal) int {
     maxLen := 10
@@ -180,7 +194,7 @@ func printHostInfoPretty(dictList []objDict, termWidth int) {
     hgrps := make([]attrVal, len(dictList))
     hosts := make([]attrVal, len(dictList))
     for i, dict := range dictList {
-        svc  := append(dict.services.enabled.ToSlice(), dict.services.others...)
+        svc  := append(dict.services.enabled.ToSlice().displayIDs(), dict.services.others...)
         hgrp := dict.hostgroups.enabled
 
         // max length of an object attribute
@@ -245,7 +259,7 @@ func (d *defs) printDef(objType string, ids ...string) {
 }
 // print host info not pretty but live ( show host as you find it )
 func printHostInfo(hostname string, hostAddr string, hostgroups hostgroupOffset, services serviceOffset) {
-    svc := append(services.enabled.ToSlice(), services.others...)
+    svc := append(services.enabled.ToSlice().displayIDs(), services.others...)
     hgrp := hostgroups.enabled
     svcSize := len(svc)
     hgrpSize := len(hgrp)
@@ -0,0 +1,310 @@
+package main
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "os/user"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// max number of changed objects listed in a commit message
+const gitMaxMsgChanges = 50
+
+// git repository holding the nagios config directory
+type gitRepo struct {
+    top         string          // top level directory of the work tree
+}
+
+// run a git command in the work tree, stderr is returned as error
+func (r *gitRepo) run(args ...string) (string, error) {
+    cmd := exec.Command("git", append([]string{"-C", r.top}, args...)...)
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+    if err := cmd.Run(); err != nil {
+        if msg := strings.TrimSpace(stderr.String()); msg != "" {
+            return stdout.String(), errors.New(msg)
+        }
+        return stdout.String(), err
+    }
+    return stdout.String(), nil
+}
+
+// git repository of the nagios config directory, nil if it is not in a git work tree or git is not installed
+func findGitRepo(root string) *gitRepo {
+    if _, err := exec.LookPath("git"); err != nil {
+        return nil
+    }
+    r := &gitRepo{root}
+    if abs, err := filepath.Abs(root); err == nil {
+        r.top = abs
+    }
+    top, err := r.run("rev-parse", "--show-toplevel"); if err != nil {
+        return nil
+    }
+    r.top = strings.TrimSpace(top)
+    return r
+}
+
+// git repository the written config files are committed to, nil if commits are disabled
+func gitRepoForChanges(root string, enabled map[string]interface{}) *gitRepo {
+    if commit, _ := enabled["git_commit"].(bool); !commit {
+        return nil
+    }
+    return findGitRepo(root)
+}
+
+// path of a file relative to the work tree, false for files outside of it
+func (r *gitRepo) relPath(name string) (string, bool) {
+    abs, err := filepath.Abs(name); if err != nil {
+        return "", false
+    }
+    // the top level reported by git has symlinks resolved
+    if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+        abs = filepath.Join(dir, filepath.Base(abs))
+    }
+    rel, err := filepath.Rel(r.top, abs); if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+        return "", false
+    }
+    return rel, true
+}
+
+// warn about config files with uncommitted changes, they would be committed together with the change set
+func (r *gitRepo) warnUncommitted(names []string) {
+    if r == nil {
+        return
+    }
+    files := []string{}
+    for _, name := range names {
+        if rel, ok := r.relPath(name); ok {
+            files = append(files, rel)
+        }
+    }
+    if len(files) == 0 {
+        return
+    }
+    out, err := r.run(append([]string{"status", "--porcelain", "--untracked-files=no", "--"}, files...)...); if err != nil {
+        return
+    }
+    for _, line := range splitLines(out) {
+        if len(line) > 3 {
+            fmt.Printf("%vWarning%v: '%v' has uncommitted changes, they are committed together with this change set\n", Yellow, RST, line[3:])
+        }
+    }
+}
+
+// subject and body of the commit of a change set: command, changed objects, user and journal id
+func gitCommitMessage(files []string, enabled map[string]interface{}, journalID int) string {
+    subject := "eznagios: " + strings.Join(os.Args[1:], " ")
+    if len(subject) > 72 {
+        subject = subject[:69] + "..."
+    }
+    body := []string{}
+    if s, ok := enabled["change_summary"].(changeSummary); ok {
+        for i, c := range s.Changes {
+            if i == gitMaxMsgChanges {
+                body = append(body, fmt.Sprintf("... and %v more", len(s.Changes)-i))
+                break
+            }
+            body = append(body, fmt.Sprintf("%v %v %v (%v)", c.State, c.Type, c.Name, c.File))
+        }
+    }
+    if id, ok := enabled["undo_of"].(int); ok {
+        body = append(body, fmt.Sprintf("undo of change set %v", id))
+    }
+    body = append(body, "", "Files:")
+    for _, f := range files {
+        body = append(body, "    "+f)
+    }
+    body = append(body, "")
+    if usr, err := user.Current(); err == nil {
+        body = append(body, "User: "+usr.Username)
+    }
+    if journalID > 0 {
+        body = append(body, fmt.Sprintf("Change-Set: %v", journalID))
+    }
+    return subject + "\n\n" + strings.TrimLeft(strings.Join(body, "\n"), "\n") + "\n"
+}
+
+// name of the branch of a change set e.g. eznagios/12-modify
+func gitBranchName(journalID int) string {
+    id := time.Now().Format("20060102-150405")
+    if journalID > 0 {
+        id = fmt.Sprint(journalID)
+    }
+    cmd := "change"
+    if len(os.Args) > 1 {
+        cmd = os.Args[1]
+    }
+    return fmt.Sprintf("eznagios/%v-%v", id, cmd)
+}
+
+// commit the written config files on the current branch, with a branch ref per change set if git_branch is set.
+// other staged changes are left alone
+func (r *gitRepo) commit(writes []*pendingWrite, enabled map[string]interface{}, journalID int) {
+    if r == nil {
+        return
+    }
+    fail := func(args []string, err error) {
+        fmt.Println(&gitError{err, "git " + strings.Join(args, " ")})
+        fmt.Printf("%vWarning%v: the config files have been written but not committed\n", Yellow, RST)
+    }
+    files := []string{}
+    for _, w := range writes {
+        // a symlinked config file changes its target, a removal the link itself
+        name := w.name
+        if !w.remove && w.target != "" {
+            name = w.target
+        }
+        rel, ok := r.relPath(name); if !ok {
+            fmt.Printf("%vWarning%v: '%v' is outside of the git work tree '%v', not committed\n", Yellow, RST, name, r.top)
+            continue
+        }
+        // removed config files git never knew about
+        if w.remove {
+            if _, err := r.run("ls-files", "--error-unmatch", "--", rel); err != nil {
+                continue
+            }
+        }
+        files = append(files, rel)
+    }
+    if len(files) == 0 {
+        return
+    }
+    args := append([]string{"add", "-A", "--"}, files...)
+    if _, err := r.run(args...); err != nil {
+        fail(args, err)
+        return
+    }
+    args = append([]string{"commit", "-q", "-m", gitCommitMessage(files, enabled, journalID), "--"}, files...)
+    if _, err := r.run(args...); err != nil {
+        fail(args[:2], err)
+        return
+    }
+    hash, _ := r.run("rev-parse", "--short", "HEAD")
+    current, _ := r.run("rev-parse", "--abbrev-ref", "HEAD")
+    fmt.Printf("Git: committed %v on branch '%v'\n", strings.TrimSpace(hash), strings.TrimSpace(current))
+    // the work tree nagios reads stays on its branch, the change set branch only points at the commit
+    if b, _ := enabled["git_branch"].(bool); b {
+        branch := gitBranchName(journalID)
+        args := []string{"branch", branch, "HEAD"}
+        if _, err := r.run(args...); err != nil {
+            fmt.Println(&gitError{err, "git " + strings.Join(args, " ")})
+            return
+        }
+        fmt.Printf("Git: created branch '%v' for the change set\n", branch)
+    }
+}
+
+// line range of an object definition in its config file, 1-based
+func blockLines(f *cfgFile, b *cfgBlock) (int, int) {
+    raw := f.raw[b.start:b.end]
+    start := b.start + len(raw) - len(strings.TrimLeft(raw, " \t\r\n"))
+    return strings.Count(f.raw[:start], "\n") + 1, strings.Count(f.raw[:b.end], "\n") + 1
+}
+
+// print the commits that touched an object definition
+func (r *gitRepo) printDefHistory(objDefs *obj, kind string, id string, bflags attrVal) {
+    f, i := objDefs.findBlock(kind, id)
+    rel, inTree := r.relPath(f.name)
+    start, end := blockLines(f, f.blocks[i])
+    label := kind + " " + displayID(id)
+    if d := (*objDefs.defsOf(kind))[id]; kind == "service" && d.attrExist("host_name") {
+        label += " (host_name " + d["host_name"].ToString() + ")"
+    }
+    if bflags.Has("color") {
+        label = Green + label + RST
+    }
+    fmt.Printf("%v  %v:%v-%v\n", label, objDefs.relPath(f.name), start, end)
+    if !inTree {
+        fmt.Printf("  config file is outside of the git work tree '%v'\n\n", r.top)
+        return
+    }
+    if _, err := r.run("ls-files", "--error-unmatch", "--", rel); err != nil {
+        fmt.Printf("  config file has never been committed\n\n")
+        return
+    }
+    if out, _ := r.run("status", "--porcelain", "--", rel); strings.TrimSpace(out) != "" {
+        fmt.Printf("  %vWarning%v: config file has uncommitted changes, the line range may not match the last commit\n", Yellow, RST)
+    }
+    args := []string{"log", fmt.Sprintf("-L%v,%v:%v", start, end, rel), "--date=short", "--format=  %h  %ad  %<(12,trunc)%an  %s"}
+    if !bflags.Has("patch") {
+        args = append(args, "--no-patch")
+    }
+    if bflags.Has("color") {
+        args = append(args, "--color=always")
+    }
+    out, err := r.run(args...); if err != nil {
+        fmt.Println(&gitError{err, "git " + strings.Join(args[:2], " ")})
+        return
+    }
+    if strings.TrimSpace(out) == "" {
+        out = "  no commits\n"
+    }
+    fmt.Println(strings.TrimRight(out, "\n") + "\n")
+}
+
+// print the commits that added or removed the name line of an object that is not defined anymore
+func (r *gitRepo) printRemovedHistory(kind string, name string, bflags attrVal) {
+    attr := idAttr(kind)
+    label := kind + " " + name
+    if bflags.Has("color") {
+        label = Yellow + label + RST
+    }
+    fmt.Printf("%v  not defined, commits that added or removed '%v %v'\n", label, attr, name)
+    // POSIX basic regex, git -G does not take Go syntax
+    ws := "[[:space:]]"
+    pattern := "^" + ws + "*" + attr + ws + ws + "*" + regexp.QuoteMeta(name) + ws + "*$"
+    args := []string{"log", "-G" + pattern, "--date=short", "--format=  %h  %ad  %<(12,trunc)%an  %s"}
+    if bflags.Has("patch") {
+        args = append(args, "-p")
+    }
+    if bflags.Has("color") {
+        args = append(args, "--color=always")
+    }
+    out, err := r.run(args...); if err != nil {
+        fmt.Println(&gitError{err, "git " + strings.Join(args[:2], " ")})
+        return
+    }
+    if strings.TrimSpace(out) == "" {
+        out = "  no commits\n"
+    }
+    fmt.Println(strings.TrimRight(out, "\n") + "\n")
+}
+
+// show the git commits that touched the definition of an object
+func historyCmd(objDefs *obj, pos []string, enabled map[string]interface{}, bflags attrVal) {
+    if len(pos) < 2 || objDefs.defsOf(pos[0]) == nil {
+        err := errors.New("expected 'history <object type> <name>...' e.g. 'history host web01', expected host, service, hostgroup, servicegroup, contact, contactgroup, command, timeperiod, hosttemplate, servicetemplate or contacttemplate")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    kind := pos[0]
+    if idAttr(kind) == "" {
+        err := fmt.Errorf("%v definitions have no name, history is not supported for them", kind)
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    root := enabled["path"].([]string)[0]
+    r := findGitRepo(root)
+    if r == nil {
+        err := errors.New("nagios config directory is not in a git work tree")
+        fmt.Println(&NotFoundError{err, "Fatal", root})
+        exit(1)
+    }
+    ids, notFound := selectObjects(objDefs, kind, pos[1:], nil)
+    for _, id := range ids {
+        if f, _ := objDefs.findBlock(kind, id); f != nil {
+            r.printDefHistory(objDefs, kind, id, bflags)
+        }
+    }
+    for _, name := range notFound {
+        r.printRemovedHistory(kind, name, bflags)
+    }
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// protected list of an object kind
+func protectedList(kind string) string {
+    switch kind {
+    case "host":
+        return "protected_hosts"
+    case "hostgroup":
+        return "protected_hostgroups"
+    case "hosttemplate", "servicetemplate", "contacttemplate":
+        return "protected_templates"
+    }
+    return ""
+}
+
+// check if a name is protected, patterns are names or regex
+func isProtected(patterns []string, name string) bool {
+    for _, p := range patterns {
+        if p == name {
+            return true
+        }
+        if reRegexItem.MatchString(p) {
+            if m, err := regexp.MatchString("^(?:"+p+")$", name); err == nil && m {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// refuse hosts that are protected before touching them (e.g. removing one of their services)
+func checkProtectedHosts(hosts []string, enabled map[string]interface{}) {
+    refused := false
+    for _, h := range hosts {
+        if isProtected(enabled["protected_hosts"].([]string), h) {
+            err := errors.New("host is protected")
+            fmt.Println(&guardError{err, h})
+            refused = true
+        }
+    }
+    if refused {
+        fmt.Println("\nRefused: no changes have been written")
+        exit(1)
+    }
+}
+
+// refuse a change set if it touches protected objects or deletes more than the limits
+// limits can be overridden with --force, protected objects can not. renamed objects are checked by their old name too
+func checkGuards(changes []objSummary, enabled map[string]interface{}, bflags attrVal) {
+    refused := false
+    numHosts, numDefs := 0, 0
+    for _, c := range changes {
+        if c.State == "deleted" {
+            numDefs += 1
+            if c.Type == "host" {
+                numHosts += 1
+            }
+        }
+        list := protectedList(c.Type)
+        if c.State == "added" || list == "" {
+            continue
+        }
+        for _, name := range []string{c.Name, c.OrigName} {
+            if name == "" || !isProtected(enabled[list].([]string), name) {
+                continue
+            }
+            state := c.State
+            if c.OrigName != "" {
+                state = "renamed"
+            }
+            err := fmt.Errorf("%v is protected and would be %v", strings.TrimSuffix(list[len("protected_"):], "s"), state)
+            fmt.Println(&guardError{err, name})
+            refused = true
+            break
+        }
+    }
+    limits := []struct {
+        name    string
+        num     int
+    }{{"max_delete_hosts", numHosts}, {"max_delete_defs", numDefs}}
+    for _, l := range limits {
+        max := enabled[l.name].(int)
+        if max <= 0 || l.num <= max {
+            continue
+        }
+        if bflags.Has("force") {
+            fmt.Printf("%vWarning%v: %v deletions exceed %v %v, forced\n", Yellow, RST, l.num, l.name, max)
+            continue
+        }
+        err := fmt.Errorf("exceeded: %v deletions, the limit is %v, use --force to override", l.num, max)
+        fmt.Println(&guardError{err, l.name})
+        refused = true
+    }
+    if refused {
+        fmt.Println("\nRefused: no changes have been written")
+        exit(1)
+    }
+}
+
+// protected lists as sorted strings for the eznagios config
+func protectedNames(vals []string) []string {
+    names := []string{}
+    for _, v := range vals {
+        if v = strings.TrimSpace(v); v != "" {
+            names = append(names, v)
+        }
+    }
+    sort.Strings(names)
+    return names
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// how a host is a member of a hostgroup
+type memberSource struct {
+    how         string          // members, regex, hostgroups, template or hostgroup_members
+    name        string          // object that provide the membership (hostgroup, host, template or nested hostgroup)
+}
+
+// find every way a host is a member of a hostgroup, excluded is true if the hostgroup members exclude the host
+func memberSources(objDefs *obj, hgName string, hostname string, visited attrVal) (sources []memberSource, excluded bool) {
+    hg := objDefs.hostgroupDefs[hgName]
+    if hg.attrExist("members") {
+        for _, item := range *hg["members"] {
+            switch {
+            case item == "!"+hostname:
+                excluded = true
+            case item == hostname:
+                sources = append(sources, memberSource{"members", hgName})
+            case item == "*":
+                sources = append(sources, memberSource{"regex", item})
+            case !strings.HasPrefix(item, "!") && reRegexItem.MatchString(item):
+                if m, _ := regexp.MatchString(item, hostname); m {
+                    sources = append(sources, memberSource{"regex", item})
+                }
+            }
+        }
+    }
+    // hostgroups directive of the host and its templates
+    if host, exist := objDefs.hostDefs[hostname]; exist {
+        tree := buildUseTree(&objDefs.hostTempDefs, hostname, "host", host, attrVal{})
+        for _, node := range effectiveSources(tree.attrSources()["hostgroups"], "hostgroups") {
+            if valueIndex(*node.d["hostgroups"], hgName) < 0 {
+                continue
+            }
+            if node == tree {
+                sources = append(sources, memberSource{"hostgroups", hostname})
+            } else {
+                sources = append(sources, memberSource{"template", node.name})
+            }
+        }
+    }
+    // nested hostgroups
+    visited.Add(hgName)
+    if hg.attrExist("hostgroup_members") {
+        for _, child := range *hg["hostgroup_members"] {
+            if _, exist := objDefs.hostgroupDefs[child]; !exist || visited.Has(child) {
+                continue
+            }
+            if s, ex := memberSources(objDefs, child, hostname, visited); len(s) > 0 && !ex {
+                sources = append(sources, memberSource{"hostgroup_members", child})
+            }
+        }
+    }
+    return sources, excluded
+}
+
+// describe a membership source
+func (s memberSource) String() string {
+    switch s.how {
+    case "members":
+        return "members of hostgroup"
+    case "regex":
+        return fmt.Sprintf("members pattern '%v'", s.name)
+    case "hostgroups":
+        return "hostgroups of host"
+    case "template":
+        return fmt.Sprintf("hostgroups of host template '%v'", s.name)
+    }
+    return fmt.Sprintf("hostgroup_members '%v'", s.name)
+}
+
+// apply an attribute change to an object and print it
+func changeAttr(objDefs *obj, kind string, id string, op string, attr string, val string, bflags attrVal) {
+    d := (*objDefs.defsOf(kind))[id]
+    before := copyDef(d)
+    warnings := applyChanges(d, inheritedAttrs(objDefs, kind, id, before), []attrChange{{op, attr, attrVal{val}}})
+    printModification(kind, id, before, d, bflags)
+    for _, w := range warnings {
+        fmt.Printf("%vWarning%v: %v: %v\n", Yellow, RST, displayID(id), w)
+    }
+}
+
+// add a host to a hostgroup according to the house style (hostgroup members or host hostgroups)
+func addMember(objDefs *obj, hgName string, hostname string, style string, bflags attrVal) {
+    sources, excluded := memberSources(objDefs, hgName, hostname, attrVal{})
+    if excluded {
+        changeAttr(objDefs, "hostgroup", hgName, "remove", "members", "!"+hostname, bflags)
+        if len(sources) > 0 {
+            return
+        }
+    } else if len(sources) > 0 {
+        fmt.Printf("%vWarning%v: host '%v' is already a member of '%v' via %v\n", Yellow, RST, hostname, hgName, sources[0])
+        return
+    }
+    if style == "hostgroups" {
+        changeAttr(objDefs, "host", hostname, "append", "hostgroups", hgName, bflags)
+        return
+    }
+    changeAttr(objDefs, "hostgroup", hgName, "append", "members", hostname, bflags)
+}
+
+// move an object (or template) off a template, the template is replaced by its own parents and
+// the attributes inherited from it are kept in the object definition, returns the attributes resolved before
+func detachUse(objDefs *obj, kind string, id string, tmpl string) def {
+    t := objDefs.templateDefs(objTypeOf(kind))
+    d := (*objDefs.defsOf(kind))[id]
+    resolved := buildUseTree(t, id, objTypeOf(kind), d, attrVal{}).resolve()
+    use := attrVal{}
+    for _, u := range *d["use"] {
+        if u != tmpl {
+            use.Add(u)
+            continue
+        }
+        if parents := (*t)[tmpl]; parents.attrExist("use") {
+            for _, p := range *parents["use"] {
+                if !use.Has(p) {
+                    use.Add(p)
+                }
+            }
+        }
+    }
+    if len(use) > 0 {
+        d["use"] = &use
+    } else {
+        delete(d, "use")
+    }
+    after := buildUseTree(t, id, objTypeOf(kind), d, attrVal{}).resolve()
+    for attr, val := range resolved {
+        if nonInheritedAttr.Has(attr) {
+            continue
+        }
+        if !after.attrExist(attr) || after[attr].ToString() != val.ToString() {
+            v := append(attrVal{}, *val...)
+            d[attr] = &v
+        }
+    }
+    return resolved
+}
+
+// move a host off a template, the attributes it inherited from the template are kept in the host definition
+func detachTemplate(objDefs *obj, hostname string, tmpl string, hgName string, bflags attrVal) {
+    host := objDefs.hostDefs[hostname]
+    before := copyDef(host)
+    resolved := detachUse(objDefs, "host", hostname, tmpl)
+    after := buildUseTree(&objDefs.hostTempDefs, hostname, "host", host, attrVal{}).resolve()
+    // hostgroups without the one the host is removed from
+    want := attrVal{}
+    for _, hg := range *resolved["hostgroups"] {
+        if hg != hgName {
+            want.Add(hg)
+        }
+    }
+    got := attrVal{}
+    if after.attrExist("hostgroups") {
+        got = *after["hostgroups"]
+    }
+    if want.ToString() != got.ToString() {
+        if len(want) == 0 {
+            want = attrVal{"null"}
+        }
+        host["hostgroups"] = &want
+    }
+    printModification("host", hostname, before, host, bflags)
+}
+
+// remove a host from a hostgroup, every source of the membership is handled
+func removeMember(objDefs *obj, hgName string, hostname string, tmplAction string, bflags attrVal) {
+    sources, excluded := memberSources(objDefs, hgName, hostname, attrVal{})
+    if excluded || len(sources) == 0 {
+        fmt.Printf("%vWarning%v: host '%v' is not a member of '%v'\n", Yellow, RST, hostname, hgName)
+        return
+    }
+    exclude := false
+    for _, s := range sources {
+        switch s.how {
+        case "members":
+            changeAttr(objDefs, "hostgroup", hgName, "remove", "members", hostname, bflags)
+        case "hostgroups":
+            changeAttr(objDefs, "host", hostname, "remove", "hostgroups", hgName, bflags)
+        case "regex":
+            exclude = true
+        case "template":
+            if tmplAction == "detach" {
+                detachTemplate(objDefs, hostname, s.name, hgName, bflags)
+            } else {
+                exclude = true
+            }
+        case "hostgroup_members":
+            fmt.Printf("%vWarning%v: host '%v' is a member of '%v' via %v, remove it from '%v' instead\n", Yellow, RST, hostname, hgName, s, s.name)
+        }
+    }
+    // membership the host can not be removed from is excluded in the hostgroup members
+    if exclude {
+        changeAttr(objDefs, "hostgroup", hgName, "append", "members", "!"+hostname, bflags)
+    }
+}
+
+// manage hostgroup membership
+func hostgroupCmd(objDefs *obj, pos []string, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    if len(pos) < 3 || pos[0] != "add-member" && pos[0] != "remove-member" {
+        err := errors.New("expected 'hostgroup <add-member|remove-member> <hostgroup> <hostname>...'")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    hgName := pos[1]
+    if _, exist := objDefs.hostgroupDefs[hgName]; !exist {
+        err := errors.New("hostgroup not found")
+        fmt.Println(&NotFoundError{err, "Fatal", hgName})
+        exit(1)
+    }
+    style := enabled["membership"].(string)
+    if style != "members" && style != "hostgroups" {
+        err := fmt.Errorf("unknown membership style '%v', expected members or hostgroups", style)
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    tmplAction := "exclude"
+    if val, ok := visited["template-action"]; ok {
+        tmplAction = val.([]string)[0]
+        if tmplAction != "exclude" && tmplAction != "detach" {
+            err := fmt.Errorf("unknown template action '%v', expected exclude or detach", tmplAction)
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+    }
+    for _, hostname := range pos[2:] {
+        if _, exist := objDefs.hostDefs[hostname]; !exist {
+            err := errors.New("host not found")
+            fmt.Println(&NotFoundError{err, "Warn", hostname})
+            continue
+        }
+        if bflags.Has("verbose") {
+            sources, excluded := memberSources(objDefs, hgName, hostname, attrVal{})
+            for _, s := range sources {
+                fmt.Printf("Member: host '%v' is a member of '%v' via %v\n", hostname, hgName, s)
+            }
+            if excluded {
+                fmt.Printf("Member: host '%v' is excluded from '%v' members\n", hostname, hgName)
+            }
+        }
+        if pos[0] == "add-member" {
+            addMember(objDefs, hgName, hostname, style, bflags)
+        } else {
+            removeMember(objDefs, hgName, hostname, tmplAction, bflags)
+        }
+    }
+    objDefs.commitChanges(enabled, bflags)
+}
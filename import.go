@@ -0,0 +1,268 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// read an inventory file, csv with a header line or json array of objects, into rows of column:value
+func readInventory(fileName string) ([]map[string]string, error) {
+    data, err := ioutil.ReadFile(fileName); if err != nil {
+        return nil, err
+    }
+    rows := []map[string]string{}
+    if strings.ToLower(filepath.Ext(fileName)) == ".json" {
+        records := []map[string]interface{}{}
+        if err := json.Unmarshal(data, &records); err != nil {
+            return nil, err
+        }
+        for _, record := range records {
+            row := make(map[string]string)
+            for col, val := range record {
+                row[col] = jsonValue(val)
+            }
+            rows = append(rows, row)
+        }
+        return rows, nil
+    }
+    r := csv.NewReader(strings.NewReader(string(data)))
+    r.Comment = '#'
+    r.TrimLeadingSpace = true
+    records, err := r.ReadAll(); if err != nil {
+        return nil, err
+    }
+    if len(records) == 0 {
+        return rows, nil
+    }
+    for _, record := range records[1:] {
+        row := make(map[string]string)
+        for i, col := range records[0] {
+            row[strings.TrimSpace(col)] = strings.TrimSpace(record[i])
+        }
+        rows = append(rows, row)
+    }
+    return rows, nil
+}
+
+// convert a json value into an attribute value, arrays become comma separated lists
+func jsonValue(val interface{}) string {
+    switch v := val.(type) {
+    case nil:
+        return ""
+    case string:
+        return strings.TrimSpace(v)
+    case float64:
+        return strconv.FormatFloat(v, 'f', -1, 64)
+    case []interface{}:
+        items := []string{}
+        for _, item := range v {
+            if s := jsonValue(item); s != "" {
+                items = append(items, s)
+            }
+        }
+        return strings.Join(items, ",")
+    }
+    return fmt.Sprintf("%v", val)
+}
+
+// parse inventory column mapping column=attr, columns mapped to '-' are ignored
+func parseColumnMap(vals []string) (map[string]string, error) {
+    columns := make(map[string]string)
+    for _, v := range vals {
+        if strings.TrimSpace(v) == "" {
+            continue
+        }
+        i := strings.Index(v, "=")
+        if i <= 0 {
+            return nil, fmt.Errorf("expected column=attr, got '%v'", v)
+        }
+        columns[strings.TrimSpace(v[:i])] = strings.TrimSpace(v[i+1:])
+    }
+    return columns, nil
+}
+
+// attribute of an inventory column, unmapped columns are used if they are host attributes or custom variables
+func columnAttr(columns map[string]string, col string) string {
+    if attr, ok := columns[col]; ok {
+        if attr == "-" {
+            return ""
+        }
+        return attr
+    }
+    if _, known := find(hostAttr, col); known || strings.HasPrefix(col, "_") {
+        return col
+    }
+    return ""
+}
+
+// convert inventory rows into hosts
+func inventoryHosts(rows []map[string]string, columns map[string]string) ([]newHost, error) {
+    hosts := []newHost{}
+    seen := attrVal{}
+    for i, row := range rows {
+        h := newHost{attrs: def{}}
+        cols := []string{}
+        for col := range row {
+            cols = append(cols, col)
+        }
+        sort.Strings(cols)
+        for _, col := range cols {
+            attr, val := columnAttr(columns, col), row[col]
+            if attr == "" || val == "" {
+                continue
+            }
+            switch attr {
+            case "host_name":
+                h.hostName = val
+            case "address":
+                h.address = val
+            case "alias":
+                h.alias = val
+            default:
+                // multiple values of a csv cell are separated by ';'
+                if !isSingleValueAttr(attr) {
+                    val = strings.Replace(val, ";", ",", -1)
+                }
+                v := splitAttrVal(attr, val)
+                if h.attrs.attrExist(attr) {
+                    v = append(*h.attrs[attr], v...)
+                }
+                h.attrs[attr] = &v
+            }
+        }
+        if h.hostName == "" {
+            return nil, fmt.Errorf("host_name is missing in inventory record %v", i+1)
+        }
+        if seen.Has(h.hostName) {
+            return nil, fmt.Errorf("host '%v' is defined more than once in the inventory", h.hostName)
+        }
+        seen.Add(h.hostName)
+        hosts = append(hosts, h)
+    }
+    return hosts, nil
+}
+
+// update an existing host with the inventory values, returns true if anything changed
+func updateInventoryHost(objDefs *obj, h newHost, style string, bflags attrVal) bool {
+    host := objDefs.hostDefs[h.hostName]
+    before := copyDef(host)
+    resolved := buildUseTree(&objDefs.hostTempDefs, h.hostName, "host", host, attrVal{}).resolve()
+    want := copyDef(h.attrs)
+    want["address"] = &attrVal{h.address}
+    if h.alias != "" {
+        want["alias"] = &attrVal{h.alias}
+    }
+    hostgroups := attrVal{}
+    if want.attrExist("hostgroups") {
+        hostgroups = *want["hostgroups"]
+        delete(want, "hostgroups")
+    }
+    changed := false
+    for _, attr := range want.sortAttrNames() {
+        if h.address == "" && attr == "address" {
+            continue
+        }
+        // values inherited from templates are not copied into the host
+        if resolved.attrExist(attr) && resolved[attr].ToString() == strings.TrimPrefix(want[attr].ToString(), "+") {
+            continue
+        }
+        host[attr] = want[attr]
+        changed = true
+    }
+    if changed {
+        printModification("host", h.hostName, before, host, bflags)
+    }
+    for _, hg := range hostgroups {
+        hg = strings.TrimLeft(hg, "+")
+        if _, exist := objDefs.hostgroupDefs[hg]; !exist {
+            err := errors.New("hostgroup not found")
+            fmt.Println(&NotFoundError{err, "Warn", hg})
+            continue
+        }
+        if sources, excluded := memberSources(objDefs, hg, h.hostName, attrVal{}); len(sources) > 0 && !excluded {
+            continue
+        }
+        addMember(objDefs, hg, h.hostName, style, bflags)
+        changed = true
+    }
+    return changed
+}
+
+// import hosts from a csv/json inventory, existing hosts are updated and hosts that are up to date are left as is
+// add new hosts of an inventory grouped by template, a 'use' column override the default template tmpl
+func addInventoryHosts(objDefs *obj, hosts []newHost, tmpl string, enabled map[string]interface{}, bflags attrVal) {
+    newHosts := make(map[string][]newHost)
+    tmpls := []string{}
+    for _, h := range hosts {
+        t := tmpl
+        if h.attrs.attrExist("use") {
+            t = h.attrs["use"].ToString()
+            delete(h.attrs, "use")
+        }
+        if t == "" {
+            err := fmt.Errorf("host '%v' has no template, use --template option or a column mapped to 'use'", h.hostName)
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        if _, ok := newHosts[t]; !ok {
+            tmpls = append(tmpls, t)
+        }
+        newHosts[t] = append(newHosts[t], h)
+    }
+    for _, t := range tmpls {
+        validateNewHosts(objDefs, newHosts[t])
+        addHostsFromTemplate(objDefs, t, newHosts[t], attrVal{}, enabled["placement"].(string), bflags)
+    }
+}
+
+func importCmd(objDefs *obj, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    fval, sf := visited["file"]
+    if !sf {
+        err := errors.New("--file option is required")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    columns := make(map[string]string)
+    if mval, ok := visited["map"]; ok {
+        var err error
+        columns, err = parseColumnMap(mval.([]string)); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+    }
+    rows, err := readInventory(fval.([]string)[0]); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    hosts, err := inventoryHosts(rows, columns); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    tmpl := ""
+    if tval, ok := visited["template"]; ok {
+        tmpl = tval.([]string)[0]
+    }
+    newHosts := []newHost{}
+    numUpdated, numUnchanged := 0, 0
+    for _, h := range hosts {
+        if _, exist := objDefs.hostDefs[h.hostName]; exist {
+            if updateInventoryHost(objDefs, h, enabled["membership"].(string), bflags) {
+                numUpdated += 1
+            } else {
+                numUnchanged += 1
+            }
+            continue
+        }
+        newHosts = append(newHosts, h)
+    }
+    addInventoryHosts(objDefs, newHosts, tmpl, enabled, bflags)
+    fmt.Printf("\nNum of hosts: %v (added: %v, updated: %v, unchanged: %v)\n\n", len(hosts), len(newHosts), numUpdated, numUnchanged)
+    objDefs.commitChanges(enabled, bflags)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+    "strings"
+)
+
+// node of the 'use' inheritance graph of a nagios object
+type useNode struct {
+    name        string          // object/template id
+    kind        string          // object kind (host, host template, ...)
+    d           def             // object definition, nil if template does not exist
+    parents     []*useNode      // templates declared in 'use' attr (nagios order)
+    cycle       bool            // template already visited on the current inheritance path
+}
+
+// attributes that nagios never inherit from a template
+var nonInheritedAttr = attrVal{"name", "use", "register"}
+
+// get template definitions of a specific nagios object type (host,service,contact)
+func (o *obj) templateDefs(objType string) *defs {
+    switch objType {
+    case "host":
+        return &o.hostTempDefs
+    case "service":
+        return &o.serviceTempDefs
+    case "contact":
+        return &o.contactTempDefs
+    }
+    return nil
+}
+
+// get registered object definitions of a specific nagios object type (host,service,contact)
+func (o *obj) objectDefs(objType string) *defs {
+    switch objType {
+    case "host":
+        return &o.hostDefs
+    case "service":
+        return &o.serviceDefs
+    case "contact":
+        return &o.contactDefs
+    }
+    return nil
+}
+
+// build the inheritance tree of an object by following its 'use' attr recursively
+func buildUseTree(t *defs, name string, kind string, d def, path attrVal) *useNode {
+    n := &useNode{name: name, kind: kind, d: d}
+    if d == nil || !d.attrExist("use") {
+        return n
+    }
+    tmplKind := strings.TrimSuffix(kind, " template") + " template"
+    for _, tmpl := range *d["use"] {
+        if path.Has(tmpl) {
+            n.parents = append(n.parents, &useNode{name: tmpl, kind: tmplKind, cycle: true})
+            continue
+        }
+        // copy path so sibling branches do not share the same backing array
+        tmplPath := append(attrVal{}, path...)
+        tmplPath.Add(tmpl)
+        n.parents = append(n.parents, buildUseTree(t, tmpl, tmplKind, (*t)[tmpl], tmplPath))
+    }
+    return n
+}
+
+// walk the inheritance tree in the same order nagios uses to resolve attributes (depth first, left to right)
+func (n *useNode) walk(fn func(node *useNode, depth int)) {
+    n.walkDepth(fn, 0)
+}
+
+func (n *useNode) walkDepth(fn func(node *useNode, depth int), depth int) {
+    fn(n, depth)
+    for _, p := range n.parents {
+        p.walkDepth(fn, depth+1)
+    }
+}
+
+// find every node that sets an attribute, ordered by nagios precedence
+func (n *useNode) attrSources() map[string][]*useNode {
+    sources := make(map[string][]*useNode)
+    n.walk(func(node *useNode, depth int) {
+        if node.d == nil {
+            return
+        }
+        for attr := range node.d {
+            if nonInheritedAttr.Has(attr) && depth > 0 {
+                continue
+            }
+            sources[attr] = append(sources[attr], node)
+        }
+    })
+    return sources
+}
+
+// resolve the effective object definition, support additive ('+') inheritance and 'null' values
+func (n *useNode) resolve() def {
+    resolved := def{}
+    sources := n.attrSources()
+    for attr, nodes := range sources {
+        val := attrVal{}
+        for _, node := range nodes {
+            nodeVal := *node.d[attr]
+            additive := len(nodeVal) > 0 && strings.HasPrefix(nodeVal[0], "+")
+            for i, v := range nodeVal {
+                if i == 0 {
+                    v = strings.TrimPrefix(v, "+")
+                }
+                if !val.Has(v) {
+                    val.Add(v)
+                }
+            }
+            if !additive {
+                break
+            }
+        }
+        // 'null' cancel the inherited value
+        if val.HasOnly("null") {
+            continue
+        }
+        resolved[attr] = &val
+    }
+    return resolved
+}
+
+// find the nodes whose value is part of the effective attribute value
+func effectiveSources(nodes []*useNode, attr string) []*useNode {
+    effective := []*useNode{}
+    for _, node := range nodes {
+        effective = append(effective, node)
+        nodeVal := *node.d[attr]
+        if len(nodeVal) == 0 || !strings.HasPrefix(nodeVal[0], "+") {
+            break
+        }
+    }
+    return effective
+}
+
+// find objects and templates that directly inherit from a template
+func findInheritors(t *defs, d *defs, tmplName string) (tmpls []string, objs []string) {
+    for id, def := range *t {
+        if def.attrExist("use") && def["use"].Has(tmplName) {
+            tmpls = append(tmpls, id)
+        }
+    }
+    for id, def := range *d {
+        if def.attrExist("use") && def["use"].Has(tmplName) {
+            objs = append(objs, id)
+        }
+    }
+    return tmpls, objs
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/user"
+    "path"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+)
+
+// applied change set, enough to put the config files back as they were
+type journalEntry struct {
+    ID          int                 `json:"id"`
+    Command     string              `json:"command"`
+    User        string              `json:"user"`
+    Time        string              `json:"time"`
+    Path        string              `json:"path"`           // nagios config directory
+    UndoOf      int                 `json:"undo_of,omitempty"`
+    UndoneBy    int                 `json:"undone_by,omitempty"`
+    Files       []journalFile       `json:"files"`
+}
+
+// config file of a journal entry
+type journalFile struct {
+    File        string              `json:"file"`
+    Existed     bool                `json:"existed"`        // false for config files created by the change set
+    Before      string              `json:"before"`         // content before the change set
+    After       string              `json:"sha256"`         // hash of the written content, empty if the file was removed
+}
+
+// journal directory, next to the eznagios config file
+func journalDir() string {
+    return path.Join(path.Dir(setConfigFile()), "journal")
+}
+
+// journal entries sorted by id
+func readJournal() []*journalEntry {
+    files, _ := filepath.Glob(filepath.Join(journalDir(), "*.json"))
+    entries := []*journalEntry{}
+    for _, f := range files {
+        data, err := ioutil.ReadFile(f); if err != nil {
+            continue
+        }
+        e := &journalEntry{}
+        if err := json.Unmarshal(data, e); err != nil {
+            fmt.Printf("%vWarning%v: invalid journal entry '%v': %v\n", Yellow, RST, f, err)
+            continue
+        }
+        entries = append(entries, e)
+    }
+    sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+    return entries
+}
+
+// save a journal entry
+func (e *journalEntry) save() error {
+    if err := os.MkdirAll(journalDir(), 0700); err != nil {
+        return err
+    }
+    jdata, err := json.MarshalIndent(e, "", "  "); if err != nil {
+        return err
+    }
+    name := filepath.Join(journalDir(), fmt.Sprintf("%v.json", e.ID))
+    tmp, err := writeTempFile(name, append(jdata, '\n'), 0600, -1, -1); if err != nil {
+        return err
+    }
+    return os.Rename(tmp, name)
+}
+
+// record written config files in the journal, the id of the change set is returned (0 if it is not recorded)
+func recordJournal(root string, writes []*pendingWrite, data map[string]string, enabled map[string]interface{}) int {
+    e := &journalEntry{
+        ID:         1,
+        Command:    strings.Join(os.Args[1:], " "),
+        Time:       time.Now().Format(time.RFC3339),
+        Path:       root,
+        Files:      []journalFile{},
+    }
+    if entries := readJournal(); len(entries) > 0 {
+        e.ID = entries[len(entries)-1].ID + 1
+    }
+    if usr, err := user.Current(); err == nil {
+        e.User = usr.Username
+    }
+    if id, ok := enabled["undo_of"].(int); ok {
+        e.UndoOf = id
+    }
+    // undo may run from any directory
+    if abs, err := filepath.Abs(root); err == nil {
+        e.Path = abs
+    }
+    for _, w := range writes {
+        f := journalFile{File: w.name, Existed: w.exist, Before: string(w.orig)}
+        if abs, err := filepath.Abs(w.name); err == nil {
+            f.File = abs
+        }
+        if content, keep := data[w.name]; keep {
+            f.After = contentHash(content)
+        }
+        e.Files = append(e.Files, f)
+    }
+    if err := e.save(); err != nil {
+        fmt.Printf("%vWarning%v: failed to record the change set in the journal: %v\n", Yellow, RST, err)
+        return 0
+    }
+    fmt.Printf("Journal: recorded change set %v, use 'undo %v' to revert it\n", e.ID, e.ID)
+    return e.ID
+}
+
+// helper function to print a journal entry
+func printJournalEntry(e *journalEntry, bflags attrVal) {
+    id := fmt.Sprintf("%4v", e.ID)
+    if bflags.Has("color") {
+        id = Green + id + RST
+    }
+    state := ""
+    if e.UndoneBy > 0 {
+        state = fmt.Sprintf(" (undone by %v)", e.UndoneBy)
+    }
+    fmt.Printf("%v  %v  %-8v %v, %v file(s)%v\n", id, e.Time, e.User, e.Command, len(e.Files), state)
+}
+
+// check that the config files are still the ones the change set wrote
+func checkJournalDrift(e *journalEntry) bool {
+    drift := false
+    for _, f := range e.Files {
+        data, err := ioutil.ReadFile(f.File)
+        switch {
+        case err != nil && f.After == "" && os.IsNotExist(err):
+        case err != nil && f.After == "":
+            fmt.Println(&driftError{err, f.File})
+            drift = true
+        case err != nil:
+            err := fmt.Errorf("has been removed since change set %v", e.ID)
+            fmt.Println(&driftError{err, f.File})
+            drift = true
+        case f.After == "":
+            err := fmt.Errorf("has been created again since change set %v", e.ID)
+            fmt.Println(&driftError{err, f.File})
+            drift = true
+        case contentHash(string(data)) != f.After:
+            err := fmt.Errorf("has changed since change set %v", e.ID)
+            fmt.Println(&driftError{err, f.File})
+            drift = true
+        }
+    }
+    return drift
+}
+
+// revert an applied change set, the last one by default
+func undoCmd(pos []string, enabled map[string]interface{}, bflags attrVal) {
+    entries := readJournal()
+    if len(pos) > 0 && pos[0] == "list" {
+        for _, e := range entries {
+            printJournalEntry(e, bflags)
+        }
+        fmt.Printf("\nNum of change sets: %v (%v)\n\n", len(entries), journalDir())
+        return
+    }
+    var entry *journalEntry
+    if len(pos) > 0 {
+        id, err := strconv.Atoi(pos[0]); if err != nil {
+            err := fmt.Errorf("expected 'undo [list|<id>]', got '%v'", pos[0])
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        for _, e := range entries {
+            if e.ID == id {
+                entry = e
+            }
+        }
+    } else {
+        // last change set that is not an undo and has not been undone
+        for _, e := range entries {
+            if e.UndoOf == 0 && e.UndoneBy == 0 {
+                entry = e
+            }
+        }
+    }
+    if entry == nil {
+        err := errors.New("change set not found in the journal")
+        fmt.Println(&NotFoundError{err, "Fatal", strings.Join(pos, "")})
+        exit(1)
+    }
+    if entry.UndoneBy > 0 {
+        err := fmt.Errorf("change set %v has already been undone by %v", entry.ID, entry.UndoneBy)
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    printJournalEntry(entry, bflags)
+    fmt.Println()
+    lock := lockForChanges(entry.Path, enabled, bflags)
+    defer lock.release()
+    if checkJournalDrift(entry) {
+        fmt.Println("\nRefused: config files changed after the change set, no changes have been written")
+        exit(1)
+    }
+    names := []string{}
+    data := make(map[string]string)
+    for _, f := range entry.Files {
+        names = append(names, f.File)
+        action := "restore"
+        if f.Existed {
+            data[f.File] = f.Before
+        } else {
+            action = "remove"
+        }
+        fmt.Printf("  %-8v %v\n", action, f.File)
+        if bflags.Has("dryrun") {
+            current, _ := ioutil.ReadFile(f.File)
+            fmt.Print(unifiedDiff(f.File, f.File, string(current), data[f.File], bflags))
+        }
+    }
+    if bflags.Has("dryrun") {
+        fmt.Println("\nDryrun: no changes have been written")
+        return
+    }
+    if !bflags.Has("yes") {
+        if confirm(fmt.Sprintf("\nUndo change set %v? [y]es, [n]o: ", entry.ID), "yn") != "y" {
+            fmt.Println("Canceled: no changes have been written")
+            return
+        }
+    }
+    enabled["undo_of"] = entry.ID
+    if !writeConfFiles(entry.Path, names, data, enabled, bflags) {
+        exit(1)
+    }
+    // the undo is the last journal entry
+    if entries = readJournal(); len(entries) > 0 {
+        entry.UndoneBy = entries[len(entries)-1].ID
+        if err := entry.save(); err != nil {
+            fmt.Printf("%vWarning%v: failed to update the journal: %v\n", Yellow, RST, err)
+        }
+    }
+}
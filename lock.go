@@ -0,0 +1,167 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/user"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+)
+
+// lock file of a nagios config directory
+const lockFileName = ".eznagios.lock"
+
+// holder of the config lock, written into the lock file
+type lockInfo struct {
+    User        string              `json:"user"`
+    Host        string              `json:"host"`
+    PID         int                 `json:"pid"`
+    Command     string              `json:"command"`
+    Started     string              `json:"started"`
+}
+
+// advisory lock of a nagios config directory, the kernel releases it if the process dies
+type configLock struct {
+    file        *os.File
+}
+
+// lock held by the run, released by exit
+var heldLock *configLock
+
+// end the run, the lock is released first so the next run does not take the holder for a stale one
+func exit(code int) {
+    heldLock.release()
+    os.Exit(code)
+}
+
+// lock file of a config directory, kept in the git directory when the config is in a git work tree so it never shows
+// up as an untracked file
+func lockFilePath(root string) string {
+    if r := findGitRepo(root); r != nil {
+        if p, err := r.run("rev-parse", "--git-path", "eznagios.lock"); err == nil {
+            p = strings.TrimSpace(p)
+            if !filepath.IsAbs(p) {
+                p = filepath.Join(r.top, p)
+            }
+            return p
+        }
+    }
+    return filepath.Join(root, lockFileName)
+}
+
+// helper function to describe a lock holder
+func (i *lockInfo) String() string {
+    return fmt.Sprintf("%v (pid %v on %v, command '%v', started %v)", i.User, i.PID, i.Host, i.Command, i.Started)
+}
+
+// read the holder of a lock file, nil if the lock file is empty
+func readLockInfo(f *os.File) *lockInfo {
+    f.Seek(0, 0)
+    data, err := ioutil.ReadAll(f); if err != nil || strings.TrimSpace(string(data)) == "" {
+        return nil
+    }
+    info := &lockInfo{}
+    if err := json.Unmarshal(data, info); err != nil {
+        return nil
+    }
+    return info
+}
+
+// check if a process of this host is still running
+func processAlive(pid int) bool {
+    err := syscall.Kill(pid, 0)
+    return err == nil || err == syscall.EPERM
+}
+
+// lock the nagios config directory for a changing command, wait up to lock_timeout seconds if it is busy
+func lockConfigTree(root string, enabled map[string]interface{}, bflags attrVal) *configLock {
+    name := lockFilePath(root)
+    f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644); if err != nil {
+        fmt.Println(&lockError{err, name})
+        exit(1)
+    }
+    timeout := time.Duration(enabled["lock_timeout"].(int)) * time.Second
+    deadline := time.Now().Add(timeout)
+    waiting := false
+    for {
+        err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+        if err == nil {
+            break
+        }
+        if err != syscall.EWOULDBLOCK {
+            fmt.Println(&lockError{err, name})
+            exit(1)
+        }
+        holder := "another eznagios process"
+        if info := readLockInfo(f); info != nil {
+            holder = info.String()
+        }
+        if time.Now().After(deadline) {
+            err := fmt.Errorf("config directory is locked by %v, gave up after %v", holder, timeout)
+            fmt.Println(&lockError{err, name})
+            exit(1)
+        }
+        if !waiting {
+            fmt.Printf("Lock: config directory is locked by %v, waiting up to %v\n", holder, timeout)
+            waiting = true
+        }
+        time.Sleep(250 * time.Millisecond)
+    }
+    // holder info left by a process that ended without releasing the lock, the kernel released the lock itself
+    if info := readLockInfo(f); info != nil {
+        host, _ := os.Hostname()
+        reason := "ended without releasing it"
+        if info.Host == host && info.PID > 0 && processAlive(info.PID) {
+            reason = "does not hold it anymore"
+        }
+        fmt.Printf("Lock: taking over the stale lock of %v, the process %v\n", info.String(), reason)
+    }
+    info := lockInfo{PID: os.Getpid(), Command: strings.Join(os.Args[1:], " "), Started: time.Now().Format(time.RFC3339)}
+    info.Host, _ = os.Hostname()
+    if usr, err := user.Current(); err == nil {
+        info.User = usr.Username
+    }
+    jdata, _ := json.Marshal(info)
+    f.Truncate(0)
+    f.WriteAt(append(jdata, '\n'), 0)
+    f.Sync()
+    heldLock = &configLock{f}
+    return heldLock
+}
+
+// lock the config directory of a run that writes config files, dry runs and plans do not write
+func lockForChanges(root string, enabled map[string]interface{}, bflags attrVal) *configLock {
+    if bflags.Has("dryrun") || enabled["plan_out"] != nil {
+        return nil
+    }
+    return lockConfigTree(root, enabled, bflags)
+}
+
+// run a command with the config directory locked while it is read and written, runs that do not write (reports,
+// dry runs and plans) are not locked
+func withConfigLock(enabled map[string]interface{}, bflags attrVal, write bool, run func()) {
+    var lock *configLock
+    if write {
+        lock = lockForChanges(enabled["path"].([]string)[0], enabled, bflags)
+    }
+    run()
+    lock.release()
+}
+
+// release the lock of the config directory
+func (l *configLock) release() {
+    if l == nil {
+        return
+    }
+    if heldLock == l {
+        heldLock = nil
+    }
+    l.file.Truncate(0)
+    l.file.Sync()
+    syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+    l.file.Close()
+}
@@ -74,91 +74,137 @@ func (d def) FindDuplicateAttrName(attrName *string, rdef rawDef, objType string
 func (a rawDef) rawParseObjAttr()  def {
     objDef := def{}
     for _,attr := range a {
-        oAttr := attrVal{}
-        oAttrVal := strings.Split(attr[2], ",")
-        for _,val := range oAttrVal {
-            oAttr.Add(strings.TrimSpace(val))
-        }
-        oAttr.Remove("")                                    // remove empty attr val silently
+        oAttr := splitAttrVal(attr[1], attr[2])
         objDef[attr[1]] = &oAttr                            // add attr to the def
     }
     return objDef
 }
 
+// split attribute value into a list of values (single value attributes are kept as is)
+func splitAttrVal(attrName string, val string) attrVal {
+    oAttr := attrVal{}
+    if isSingleValueAttr(attrName) {
+        oAttr.Add(strings.TrimSpace(val))
+    } else {
+        for _, v := range strings.Split(val, ",") {
+            oAttr.Add(strings.TrimSpace(v))
+        }
+    }
+    oAttr.Remove("")                                        // remove empty attr val silently
+    return oAttr
+}
+
 // parse Nagios object attributes; attr[1]-> attrName, attr[2]->attrVal
 func parseObjAttr( rawObjDef []string, reAttr *regexp.Regexp, objType string )  def {
     objDef := def{}
     mAttr := reAttr.FindAllStringSubmatch(rawObjDef[2], -1)
     for _,attr := range mAttr {
-        oAttr := attrVal{}
-        oAttrVal := strings.Split(attr[2], ",")
-        for _,val := range oAttrVal {
-            oAttr.Add(strings.TrimSpace(val))
-        }
-        oAttr.Remove("")                                            // remove empty attr val silently
+        oAttr := splitAttrVal(attr[1], attr[2])
         objDef.FindDuplicateAttrName(&attr[1], mAttr, objType)      // check for duplicate attr name
         objDef[attr[1]] = &oAttr                                     // add attr to the def
     }
     return objDef
 }
 
-// Get Nagios objects definitions
-func getObjDefs(data string) (*obj, error) {
-    objDefs := newObj()
+// parse Nagios timeperiod attributes, time range directives (e.g. 'day 1 - 15 00:00-24:00') are named after
+// everything before the time ranges
+func parseTimeperiodAttr(rawObjDef []string, reAttr *regexp.Regexp) def {
+    reRange := regexp.MustCompile(`^(.*?)\s+(\d{1,2}:\d{2}-\d{1,2}:\d{2}.*)$`)
+    objDef := def{}
+    for _, attr := range reAttr.FindAllStringSubmatch(rawObjDef[2], -1) {
+        name, val := attr[1], attr[2]
+        if m := reRange.FindStringSubmatch(strings.TrimSpace(attr[1]+" "+attr[2])); m != nil {
+            name, val = strings.Join(strings.Fields(m[1]), " "), m[2]
+        }
+        oAttr := splitAttrVal(name, val)
+        objDef[name] = &oAttr
+    }
+    return objDef
+}
+
+// Parse $USERn$ macros defined in nagios resource files
+func parseResourceMacros(data string, macros map[string]string) {
+    reResource := regexp.MustCompile(`(?m)^\s*\$(USER[0-9]+)\$\s*=(.*)$`)
+    for _, m := range reResource.FindAllStringSubmatch(data, -1) {
+        macros[m[1]] = strings.TrimSpace(m[2])
+    }
+}
+
+// Get Nagios objects definitions of a config file, object location is recorded in the file layout
+func getObjDefs(objDefs *obj, data string, fileName string) {
     reAttr := regexp.MustCompile(`\s*(?P<attr>.*?)\s+(?P<value>.*)\n`)
     reObjDef := regexp.MustCompile(`(?sm)(^\s*define\s+[a-z]+?\s*{)(.*?\n)(\s*})`)
     rawObjDefs := reObjDef.FindAllStringSubmatch(data, -1)
-    c1,c2 := 0, 0           // hostdependency and servicedependency does not have a unique identifier, will use index instead
-    if rawObjDefs != nil {
-        for _,oDef:= range rawObjDefs {
-            defStart := strings.Join(strings.Fields(oDef[1]),"")
-            objType := strings.TrimSpace(oDef[1])
-            objAttrs := parseObjAttr(oDef, reAttr, objType)
-            switch defStart {
-            case "definehost{":
-                if objAttrs.attrExist("name"){
-                    objDefs.SetHostTempDefs(objAttrs)
-                } else {
-                    objDefs.SetHostDefs(objAttrs)
-                }
-            case "defineservice{":
-                if objAttrs.attrExist("name"){
-                    objDefs.SetServiceTempDefs(objAttrs)
-                } else {
-                    objDefs.SetServiceDefs(objAttrs)
-                }
-            case "definehostgroup{":
-                objDefs.SetHostGroupDefs(objAttrs)
-            case "definehostdependency{":
-                c1 += 1
-                objDefs.SetHostDependencyDefs(objAttrs, c1)
-            case "defineservicedependency{":
-                c2 += 1
-                objDefs.SetServiceDependencyDefs(objAttrs, c2)
-            case "definecontact{":
-                if objAttrs.attrExist("name"){
-                    objDefs.SetContactTempDefs(objAttrs)
-                } else {
-                    objDefs.SetContactDefs(objAttrs)
-                }
-            case "definecontactgroup{":
-                objDefs.SetContactGroupDefs(objAttrs)
-            case "definecommand{":
-                if objAttrs.attrExist("command_name") && objAttrs.attrExist("command_line"){
-                    objDefs.SetcommandDefs(objAttrs)
-                }else {
-                    fmt.Println("here",objAttrs)
-                }
-            default:
-                err := errors.New("unknown naigos object type")
-                fmt.Println(&unknownObjectError{objAttrs,objType,err})
+    rawObjIdx := reObjDef.FindAllStringIndex(data, -1)
+    // $USERn$ macros from resource files
+    parseResourceMacros(data, objDefs.resourceMacros)
+    cfg := objDefs.addConfFile(fileName, data)
+    // dependencies and escalations does not have a unique identifier, will use index instead
+    c1,c2 := len(objDefs.hostdependencyDefs), len(objDefs.servicedependencyDefs)
+    c3,c4 := len(objDefs.hostescalationDefs), len(objDefs.serviceescalationDefs)
+    for i,oDef:= range rawObjDefs {
+        defStart := strings.Join(strings.Fields(oDef[1]),"")
+        objType := strings.TrimSpace(oDef[1])
+        var objAttrs def
+        if defStart == "definetimeperiod{" {
+            objAttrs = parseTimeperiodAttr(oDef, reAttr)
+        } else {
+            objAttrs = parseObjAttr(oDef, reAttr, objType)
+        }
+        kind, id := "", ""
+        switch defStart {
+        case "definehost{":
+            if objAttrs.attrExist("name"){
+                kind, id = "hosttemplate", objDefs.SetHostTempDefs(objAttrs)
+            } else {
+                kind, id = "host", objDefs.SetHostDefs(objAttrs)
+            }
+        case "defineservice{":
+            if objAttrs.attrExist("name"){
+                kind, id = "servicetemplate", objDefs.SetServiceTempDefs(objAttrs)
+            } else {
+                kind, id = "service", objDefs.SetServiceDefs(objAttrs)
+            }
+        case "definehostgroup{":
+            kind, id = "hostgroup", objDefs.SetHostGroupDefs(objAttrs)
+        case "definehostdependency{":
+            c1 += 1
+            kind, id = "hostdependency", objDefs.SetHostDependencyDefs(objAttrs, c1)
+        case "defineservicedependency{":
+            c2 += 1
+            kind, id = "servicedependency", objDefs.SetServiceDependencyDefs(objAttrs, c2)
+        case "definehostescalation{":
+            c3 += 1
+            kind, id = "hostescalation", objDefs.SetHostEscalationDefs(objAttrs, c3)
+        case "defineserviceescalation{":
+            c4 += 1
+            kind, id = "serviceescalation", objDefs.SetServiceEscalationDefs(objAttrs, c4)
+        case "definecontact{":
+            if objAttrs.attrExist("name"){
+                kind, id = "contacttemplate", objDefs.SetContactTempDefs(objAttrs)
+            } else {
+                kind, id = "contact", objDefs.SetContactDefs(objAttrs)
+            }
+        case "definecontactgroup{":
+            kind, id = "contactgroup", objDefs.SetContactGroupDefs(objAttrs)
+        case "definetimeperiod{":
+            kind, id = "timeperiod", objDefs.SetTimeperiodDefs(objAttrs)
+        case "defineservicegroup{":
+            kind, id = "servicegroup", objDefs.SetServiceGroupDefs(objAttrs)
+        case "definecommand{":
+            if objAttrs.attrExist("command_name") && objAttrs.attrExist("command_line"){
+                kind, id = "command", objDefs.SetcommandDefs(objAttrs)
+            }else {
+                fmt.Println("here",objAttrs)
+            }
+        default:
+            err := errors.New("unknown naigos object type")
+            fmt.Println(&unknownObjectError{objAttrs,objType,err})
+        }
+        if kind != "" {
+            cfg.addBlock(kind, id, rawObjIdx[i][0], rawObjIdx[i][1], objAttrs)
         }
     }
-    } else {
-        err := errors.New("no nagios object definition found")
-        return  nil,&NotFoundError{err, "Fatal", ""} 
-    }
-    return objDefs, nil
 }
 
 // Find hostgroup association (hostgroups that belong to a specific host)
@@ -428,6 +474,27 @@ func findHostTemplate(t *defs, hOffset *hostOffset, tmplName string ){
     }
 }
 
+// delete hosts and their hostgroups and services association, then every other reference to them
+func deleteHosts(objDefs *obj, hosts []string, bflags attrVal) {
+    for _, h := range hosts {
+        // search for host object
+        host := findHost(&objDefs.hostDefs, &objDefs.hostTempDefs, h)
+        // serach hostgroups association
+        hostgroups := findHostGroups(&objDefs.hostgroupDefs, &objDefs.hostTempDefs, host)
+        // search services association
+        services := findServices(&objDefs.serviceDefs, &objDefs.serviceTempDefs, hostgroups, h)
+        // perform deletion
+        deleteHost(&objDefs.hostDefs, &objDefs.hostTempDefs, &host, bflags)
+        deleteHostgroup(objDefs, &hostgroups, h, bflags)
+        deleteService(objDefs, &services, hostgroups.deleted, h, bflags)
+        // parents, dependencies, escalations and servicegroup members
+        removeObjRefs(objDefs, "host", h, bflags)
+        for _, hg := range hostgroups.deleted {
+            removeObjRefs(objDefs, "hostgroup", hg, bflags)
+        }
+    }
+}
+
 // delete host obj
 func deleteHost(hd *defs, td *defs, h *hostOffset, bflags attrVal){
     if len(*(*hd)[h.hostIndex]["host_name"]) > 1 {
@@ -557,14 +624,10 @@ func deleteServiceTemplate(sd *defs, st *defs, ht *defs, svc *serviceOffset, tmp
                 }
             } else if (*st)[t].attrExist("use") && !isTemplateBeingUsed(sd, st, t){
                 if isSafeDeleteTemplate(st, *(*st)[t]["use"], hgrpDeleted, hostname) {
-                    fmt.Printf("%vWarning%v:%v[SVCTMPL]%v: found template not being used '%v'", Yellow, RST,Blue,RST, t)
-                    // TODO: flag to allow not used template deletion
-//                        printDeletion(t, "SVCTMPL", "", "", "def")
-//                        svc.SetDeletedTemplate(t)
-//                        delete(*st, t)
+                    fmt.Printf("%vWarning%v:%v[SVCTMPL]%v: found template not being used '%v', use 'prune --types servicetemplate' to delete it\n", Yellow, RST,Blue,RST, t)
                 }
             } else if !isTemplateBeingUsed(sd, st, t){
-                fmt.Printf("%vWarning%v:%v[SVCTMPL]%v: found template not being used '%v'", Yellow, RST,Blue,RST, t)
+                fmt.Printf("%vWarning%v:%v[SVCTMPL]%v: found template not being used '%v', use 'prune --types servicetemplate' to delete it\n", Yellow, RST,Blue,RST, t)
             }
         }
     }
@@ -741,28 +804,3 @@ func deleteHostgroupMembership(hgd *defs, td *defs, hgrp *hostgroupOffset, hgrpN
         }
     }
 }
-
-// write chagnes to a file
-func WriteFile(d *defs, fileName string, objName string, bflags attrVal) {
-    objType, attrLen := getMaxAttr(objName)
-    f, err := os.Create(fileName); if err != nil {
-        fmt.Println(err)
-        f.Close()
-        return
-    }
-    defer func(){
-        err := f.Close(); if err != nil {
-            fmt.Println("Failed to close file ", err)
-        }
-        if bflags.Has("color"){
-            fmt.Printf("%vWrite%v: created a new config file '%v'\n",Green,RST,fileName)
-        }else {
-            fmt.Printf("Write: created a new config file '%v'\n",fileName)
-        }
-    }()
-    // write nagios objects to a file
-    for _, def := range *d {
-        formatDef := formatObjDef(def, objType, attrLen)
-        f.WriteString(formatDef)
-    }
-}
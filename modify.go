@@ -0,0 +1,419 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// attribute change applied to every matched object
+type attrChange struct {
+    op          string          // set, unset, append, remove or replace
+    attr        string          // attribute name
+    vals        attrVal         // new values, values to append/remove or old:new pairs
+}
+
+// query condition to select objects e.g. contact_groups=admins, check_period!=24x7, host_name~^web
+type queryCond struct {
+    attr        string          // attribute name
+    op          string          // '=', '!=', '~' (regex) or 'exist'
+    value       string          // value to compare with
+}
+
+var reQueryCond = regexp.MustCompile(`^([^=!~]+?)\s*(!=|=|~)\s*(.*)$`)
+
+// attribute that identify an object of a kind (can not be modified, use rename instead)
+func idAttr(kind string) string {
+    switch kind {
+    case "host":
+        return "host_name"
+    case "service":
+        return "service_description"
+    case "hosttemplate", "servicetemplate", "contacttemplate":
+        return "name"
+    case "hostgroup", "servicegroup", "contact", "contactgroup", "command", "timeperiod":
+        return kind + "_name"
+    }
+    return ""
+}
+
+// replace whole '!' separated parts of a value e.g. the command name or an argument of check_command,
+// old may span several parts (check_http!80)
+func replaceTokens(value string, oldVal string, newVal string) (string, bool) {
+    tokens := strings.Split(value, "!")
+    old := strings.Split(oldVal, "!")
+    out := []string{}
+    replaced := false
+    for i := 0; i < len(tokens); {
+        match := i+len(old) <= len(tokens)
+        for j := 0; match && j < len(old); j++ {
+            match = tokens[i+j] == old[j]
+        }
+        if match {
+            out = append(out, newVal)
+            i += len(old)
+            replaced = true
+            continue
+        }
+        out = append(out, tokens[i])
+        i++
+    }
+    return strings.Join(out, "!"), replaced
+}
+
+// parse query conditions, a condition without operator match objects that have the attribute
+func parseQuery(vals []string) ([]queryCond, error) {
+    conds := []queryCond{}
+    for _, v := range vals {
+        v = strings.TrimSpace(v)
+        if v == "" {
+            continue
+        }
+        m := reQueryCond.FindStringSubmatch(v)
+        if m == nil {
+            conds = append(conds, queryCond{attr: v, op: "exist"})
+            continue
+        }
+        if m[2] == "~" {
+            if _, err := regexp.Compile(m[3]); err != nil {
+                return nil, err
+            }
+        }
+        conds = append(conds, queryCond{strings.TrimSpace(m[1]), m[2], m[3]})
+    }
+    return conds, nil
+}
+
+// check if an attribute value has an item, the '+' additive prefix is ignored while '!' exclusion is significant
+func hasValue(val attrVal, v string) bool {
+    return valueIndex(val, v) >= 0 || val.ToString() == v
+}
+
+// index of an item in an attribute value (ignoring the '+' additive prefix), -1 if not found
+func valueIndex(val attrVal, v string) int {
+    for i, item := range val {
+        if i == 0 {
+            item = strings.TrimPrefix(item, "+")
+        }
+        if item == v {
+            return i
+        }
+    }
+    return -1
+}
+
+// check if an object definition match a query condition
+func (c queryCond) match(d def) bool {
+    if !d.attrExist(c.attr) {
+        return c.op == "!="
+    }
+    val := *d[c.attr]
+    switch c.op {
+    case "=":
+        return hasValue(val, c.value)
+    case "!=":
+        return !hasValue(val, c.value)
+    case "~":
+        re := regexp.MustCompile(c.value)
+        for i, item := range val {
+            if i == 0 {
+                item = strings.TrimPrefix(item, "+")
+            }
+            if re.MatchString(item) {
+                return true
+            }
+        }
+        return re.MatchString(val.ToString())
+    }
+    return true
+}
+
+// select objects of a kind by name (or regex) and query conditions
+func selectObjects(objDefs *obj, kind string, names []string, conds []queryCond) (ids []string, notFound []string) {
+    reRegex := regexp.MustCompile(`\{|\[|\*|\^|\(|\$|\+|\?`)
+    d := objDefs.defsOf(kind)
+    matched := attrVal{}
+    for _, id := range d.sortedIDs() {
+        if len(names) > 0 {
+            found := false
+            for _, name := range names {
+                if name == displayID(id) {
+                    found = true
+                } else if reRegex.MatchString(name) {
+                    if m, _ := regexp.MatchString(name, displayID(id)); m {
+                        found = true
+                    }
+                }
+                if found {
+                    matched.Add(name)
+                    break
+                }
+            }
+            if !found {
+                continue
+            }
+        }
+        match := true
+        for _, c := range conds {
+            if !c.match((*d)[id]) {
+                match = false
+                break
+            }
+        }
+        if match {
+            ids = append(ids, id)
+        }
+    }
+    for _, name := range names {
+        if !matched.Has(name) {
+            notFound = append(notFound, name)
+        }
+    }
+    return ids, notFound
+}
+
+// values inherited from the templates of an object (the object own attributes are ignored)
+func inheritedAttrs(objDefs *obj, kind string, id string, d def) def {
+    t := objDefs.templateDefs(objTypeOf(kind))
+    if t == nil || !d.attrExist("use") {
+        return def{}
+    }
+    parents := def{"use": d["use"]}
+    path := attrVal{}
+    if strings.HasSuffix(kind, "template") {
+        path.Add(id)
+    }
+    return buildUseTree(t, id, objTypeOf(kind), parents, path).resolve()
+}
+
+// apply attribute changes to an object definition, returns warnings about changes that could not be applied
+func applyChanges(d def, inherited def, changes []attrChange) (warnings []string) {
+    for _, c := range changes {
+        cur := attrVal{}
+        exist := d.attrExist(c.attr)
+        if exist {
+            cur = append(cur, *d[c.attr]...)
+        }
+        switch c.op {
+        case "set":
+            cur = append(attrVal{}, c.vals...)
+        case "unset":
+            delete(d, c.attr)
+            continue
+        case "append":
+            for _, v := range c.vals {
+                if hasValue(cur, v) {
+                    continue
+                }
+                // appending an excluded value cancel the exclusion
+                if i := valueIndex(cur, "!"+v); i >= 0 {
+                    cur = append(cur[:i], cur[i+1:]...)
+                    if cur.Has("*") {
+                        continue
+                    }
+                }
+                cur.Add(v)
+            }
+            // keep the values inherited from templates
+            if !exist && inherited.attrExist(c.attr) && len(cur) > 0 {
+                cur[0] = "+" + cur[0]
+            }
+        case "remove":
+            if !exist {
+                if inherited.attrExist(c.attr) {
+                    warnings = append(warnings, fmt.Sprintf("%v is inherited from a template, nothing removed", c.attr))
+                }
+                continue
+            }
+            for _, v := range c.vals {
+                i := valueIndex(cur, v)
+                switch {
+                case i >= 0:
+                    additive := i == 0 && strings.HasPrefix(cur[0], "+")
+                    cur = append(cur[:i], cur[i+1:]...)
+                    if additive && len(cur) > 0 && !strings.HasPrefix(cur[0], "+") {
+                        cur[0] = "+" + cur[0]
+                    }
+                case cur.Has("*") && !strings.HasPrefix(v, "!"):
+                    // exclude the value from the wildcard
+                    cur.Add("!" + v)
+                default:
+                    warnings = append(warnings, fmt.Sprintf("%v has no value '%v'", c.attr, v))
+                }
+            }
+        case "replace":
+            if !exist {
+                warnings = append(warnings, fmt.Sprintf("%v is not defined", c.attr))
+                continue
+            }
+            for _, pair := range c.vals {
+                oldVal, newVal := pair, ""
+                if i := strings.Index(pair, ":"); i >= 0 {
+                    oldVal, newVal = pair[:i], pair[i+1:]
+                }
+                // single value attributes (e.g. check_command) replace whole '!' separated parts of the value
+                if isSingleValueAttr(c.attr) {
+                    val, ok := replaceTokens(cur.ToString(), oldVal, newVal)
+                    if !ok {
+                        warnings = append(warnings, fmt.Sprintf("%v has no value '%v'", c.attr, oldVal))
+                        continue
+                    }
+                    cur = attrVal{val}
+                    continue
+                }
+                // the '+' and '!' prefixes of the replaced value are kept
+                replaced := false
+                for i, item := range cur {
+                    prefix := ""
+                    if i == 0 && strings.HasPrefix(item, "+") {
+                        prefix, item = "+", item[1:]
+                    }
+                    if strings.HasPrefix(item, "!") && !strings.HasPrefix(oldVal, "!") {
+                        prefix, item = prefix+"!", item[1:]
+                    }
+                    if item == oldVal {
+                        cur[i] = prefix + newVal
+                        replaced = true
+                    }
+                }
+                if !replaced {
+                    warnings = append(warnings, fmt.Sprintf("%v has no value '%v'", c.attr, oldVal))
+                }
+            }
+        }
+        if len(cur) == 0 {
+            delete(d, c.attr)
+            continue
+        }
+        d[c.attr] = &cur
+    }
+    return warnings
+}
+
+// helper function to print the attributes of an object before and after the modification
+func printModification(kind string, id string, before def, after def, bflags attrVal) int {
+    attrs := attrVal{}
+    for attr := range before {
+        if !after.attrExist(attr) || before[attr].ToString() != after[attr].ToString() {
+            attrs.Add(attr)
+        }
+    }
+    for attr := range after {
+        if !before.attrExist(attr) {
+            attrs.Add(attr)
+        }
+    }
+    if len(attrs) == 0 {
+        return 0
+    }
+    attrs.SortAttrVal()
+    width := MaxLen(&attrs) + 2
+    codeName := strings.ToUpper(objTypeOf(kind))
+    if strings.HasSuffix(kind, "template") {
+        codeName += " TEMPLATE"
+    }
+    minus, plus := "-", "+"
+    if bflags.Has("color") {
+        fmt.Printf("%vModify%v:%v[%v]%v: %v\n", Green, RST, Blue, codeName, RST, displayID(id))
+        minus, plus = Red+"-"+RST, Green+"+"+RST
+    } else {
+        fmt.Printf("Modify:[%v]: %v\n", codeName, displayID(id))
+    }
+    for _, attr := range attrs {
+        if before.attrExist(attr) {
+            fmt.Printf("    %v %-*v%v\n", minus, width, attr, before[attr].ToString())
+        }
+        if after.attrExist(attr) {
+            fmt.Printf("    %v %-*v%v\n", plus, width, attr, after[attr].ToString())
+        }
+    }
+    return 1
+}
+
+// parse modify operations from the command line flags
+func parseChanges(visited map[string]interface{}) ([]attrChange, error) {
+    changes := []attrChange{}
+    for _, op := range []string{"set", "unset", "append", "remove", "replace"} {
+        val, ok := visited[op]
+        if !ok {
+            continue
+        }
+        if op == "unset" {
+            for _, attr := range val.([]string) {
+                if attr = strings.TrimSpace(attr); attr != "" {
+                    changes = append(changes, attrChange{op: op, attr: attr})
+                }
+            }
+            continue
+        }
+        attrs, err := parseAttrFlags(val.([]string)); if err != nil {
+            return nil, err
+        }
+        for _, attr := range attrs.sortAttrNames() {
+            changes = append(changes, attrChange{op: op, attr: attr, vals: *attrs[attr]})
+        }
+    }
+    return changes, nil
+}
+
+// modify attributes of every object matching the name/query
+func modifyCmd(objDefs *obj, pos []string, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    if len(pos) == 0 || objDefs.defsOf(pos[0]) == nil {
+        err := errors.New("object type is required e.g. 'modify host', expected host, service, hostgroup, servicegroup, contact, contactgroup, command, hostdependency, servicedependency, hostescalation, serviceescalation, hosttemplate, servicetemplate or contacttemplate")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    kind := pos[0]
+    nval, sn := visited["name"]
+    qval, sq := visited["query"]
+    if !sn && !sq {
+        err := errors.New("--name or --query option is required")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    names, conds := []string{}, []queryCond{}
+    if sn {
+        names = nval.([]string)
+    }
+    if sq {
+        var err error
+        conds, err = parseQuery(qval.([]string)); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+    }
+    changes, err := parseChanges(visited); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    if len(changes) == 0 {
+        err := errors.New("one of --set, --unset, --append, --remove or --replace option is required")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    for _, c := range changes {
+        if c.attr == idAttr(kind) {
+            err := fmt.Errorf("'%v' identify the object and can not be modified, use rename instead", c.attr)
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+    }
+    ids, notFound := selectObjects(objDefs, kind, names, conds)
+    for _, v := range notFound {
+        err := errors.New("object not found")
+        fmt.Println(&NotFoundError{err, "Warn", v})
+    }
+    d := objDefs.defsOf(kind)
+    numModified := 0
+    for _, id := range ids {
+        before := copyDef((*d)[id])
+        warnings := applyChanges((*d)[id], inheritedAttrs(objDefs, kind, id, before), changes)
+        numModified += printModification(kind, id, before, (*d)[id], bflags)
+        for _, w := range warnings {
+            fmt.Printf("%vWarning%v: %v: %v\n", Yellow, RST, displayID(id), w)
+        }
+    }
+    fmt.Printf("\nNum of matched objects: %v, modified: %v\n\n", len(ids), numModified)
+    objDefs.commitChanges(enabled, bflags)
+}
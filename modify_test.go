@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestReplaceTokens(t *testing.T) {
+    tests := []struct {
+        value   string
+        old     string
+        new     string
+        want    string
+        ok      bool
+    }{
+        {"check_http!80", "check_http", "check_https", "check_https!80", true},
+        {"check_http_port!80", "check_http", "check_https", "check_http_port!80", false},
+        {"check_tcp!check_http", "check_http", "check_https", "check_tcp!check_https", true},
+        {"check_http!8080", "80", "443", "check_http!8080", false},
+        {"check_http!80!/index", "check_http!80", "check_https!443", "check_https!443!/index", true},
+        {"A1", "A1", "B2", "B2", true},
+    }
+    for _, tt := range tests {
+        got, ok := replaceTokens(tt.value, tt.old, tt.new)
+        if got != tt.want || ok != tt.ok {
+            t.Errorf("replaceTokens(%q, %q, %q) = %q, %v, want %q, %v", tt.value, tt.old, tt.new, got, ok, tt.want, tt.ok)
+        }
+    }
+}
@@ -3,6 +3,7 @@ package main
 import (
 //	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	//    "fmt"
 )
@@ -20,12 +21,19 @@ type obj struct {
     hostgroupDefs           defs        // nagios hostgroup object definitions
     hostdependencyDefs      defs        // nagios hostdependency object definition
     servicedependencyDefs   defs        // nagios servicedependency object definition
+    hostescalationDefs      defs        // nagios hostescalation object definition
+    serviceescalationDefs   defs        // nagios serviceescalation object definition
     contactDefs             defs        // nagios contact object definition
     contactgroupDefs        defs        // nagios contactgroup object definition
+    servicegroupDefs        defs        // nagios servicegroup object definition
     commandDefs             defs        // nagios command object definition
+    timeperiodDefs          defs        // nagios timeperiod object definition
     hostTempDefs            defs        // nagios host template object definition
     serviceTempDefs         defs        // nagios service template object definition
     contactTempDefs         defs        // nagios contact template object definition
+    resourceMacros          map[string]string   // $USERn$ macros defined in resource files
+    files                   []*cfgFile          // layout of the nagios config files (object location)
+    path                    string              // nagios configs directory
 }
 
 // nagios service obj struct
@@ -110,10 +118,15 @@ func newObj() *obj {
     o.serviceDefs = make(defs)
     o.serviceTempDefs = make(defs)
     o.servicedependencyDefs = make(defs)
+    o.hostescalationDefs = make(defs)
+    o.serviceescalationDefs = make(defs)
     o.commandDefs  = make(defs)
     o.contactDefs  = make(defs)
     o.contactTempDefs  = make(defs)
     o.contactgroupDefs  = make(defs)
+    o.servicegroupDefs  = make(defs)
+    o.timeperiodDefs  = make(defs)
+    o.resourceMacros = make(map[string]string)
     return o
 }
 
@@ -159,57 +172,121 @@ func newHostGroupOffset() *hostgroupOffset{
     return o
 }
 
-func (o *obj) SetContactTempDefs(contactTempDef def) {
-    ID := contactTempDef["name"].ToString()
-    o.contactDefs[ID] = contactTempDef
+func (o *obj) SetContactTempDefs(contactTempDef def) string {
+    ID := uniqueID(o.contactTempDefs, contactTempDef["name"].ToString())
+    o.contactTempDefs[ID] = contactTempDef
+    return ID
 }
 
-func (o *obj) SetHostTempDefs(hostTempDef def) {
-    ID := hostTempDef["name"].ToString()
+func (o *obj) SetHostTempDefs(hostTempDef def) string {
+    ID := uniqueID(o.hostTempDefs, hostTempDef["name"].ToString())
     o.hostTempDefs[ID] = hostTempDef
+    return ID
 }
 
-func (o *obj) SetServiceTempDefs(serviceTempDef def) {
-    ID := serviceTempDef["name"].ToString()
+func (o *obj) SetServiceTempDefs(serviceTempDef def) string {
+    ID := uniqueID(o.serviceTempDefs, serviceTempDef["name"].ToString())
     o.serviceTempDefs[ID] = serviceTempDef
+    return ID
 }
 
-func (o *obj) SetHostDefs(hostDef def) {
-    ID := hostDef["host_name"].ToString()
+func (o *obj) SetHostDefs(hostDef def) string {
+    ID := uniqueID(o.hostDefs, hostDef["host_name"].ToString())
     o.hostDefs[ID] = hostDef
+    return ID
 }
 
-func (o *obj) SetHostGroupDefs(hostgroupDef def) {
-    ID := hostgroupDef["hostgroup_name"].ToString()
+func (o *obj) SetHostGroupDefs(hostgroupDef def) string {
+    ID := uniqueID(o.hostgroupDefs, hostgroupDef["hostgroup_name"].ToString())
     o.hostgroupDefs[ID] = hostgroupDef
+    return ID
 }
 
-func (o *obj) SetServiceDefs(serviceDef def) {
-    ID := serviceDef["service_description"].ToString()
+func (o *obj) SetServiceDefs(serviceDef def) string {
+    ID := uniqueID(o.serviceDefs, serviceDef["service_description"].ToString())
     o.serviceDefs[ID] = serviceDef
+    return ID
 }
 
-func (o *obj) SetContactDefs(contactDef def) {
-    ID := contactDef["contact_name"].ToString()
+// make object id unique, nagios allows multiple service definitions with the same service_description
+func uniqueID(d defs, id string) string {
+    if _, exist := d[id]; !exist {
+        return id
+    }
+    for i := 2; ; i++ {
+        uid := id + "#" + strconv.Itoa(i)
+        if _, exist := d[uid]; !exist {
+            return uid
+        }
+    }
+}
+
+// strip the unique suffix added by uniqueID
+func displayID(id string) string {
+    if i := strings.LastIndex(id, "#"); i != -1 {
+        if _, err := strconv.Atoi(id[i+1:]); err == nil {
+            return id[:i]
+        }
+    }
+    return id
+}
+
+func (o *obj) SetContactDefs(contactDef def) string {
+    ID := uniqueID(o.contactDefs, contactDef["contact_name"].ToString())
     o.contactDefs[ID] = contactDef
+    return ID
 }
 
-func (o *obj) SetContactGroupDefs(contactgroupDef def) {
-    ID := contactgroupDef["contactgroup_name"].ToString()
+func (o *obj) SetContactGroupDefs(contactgroupDef def) string {
+    ID := uniqueID(o.contactgroupDefs, contactgroupDef["contactgroup_name"].ToString())
     o.contactgroupDefs[ID] = contactgroupDef
+    return ID
 }
 
-func (o *obj) SetcommandDefs(commandDef def) {
-    ID := commandDef["command_name"].ToString()
+func (o *obj) SetTimeperiodDefs(timeperiodDef def) string {
+    name := "timeperiod_name"
+    if !timeperiodDef.attrExist(name) {
+        name = "name"
+    }
+    ID := uniqueID(o.timeperiodDefs, timeperiodDef[name].ToString())
+    o.timeperiodDefs[ID] = timeperiodDef
+    return ID
+}
+
+func (o *obj) SetServiceGroupDefs(servicegroupDef def) string {
+    ID := uniqueID(o.servicegroupDefs, servicegroupDef["servicegroup_name"].ToString())
+    o.servicegroupDefs[ID] = servicegroupDef
+    return ID
+}
+
+func (o *obj) SetcommandDefs(commandDef def) string {
+    ID := uniqueID(o.commandDefs, commandDef["command_name"].ToString())
     o.commandDefs[ID] = commandDef
+    return ID
+}
+
+func (o *obj) SetHostDependencyDefs(hostdependencyDef def, idx int) string {
+    ID := strconv.Itoa(idx)
+    o.hostdependencyDefs[ID] = hostdependencyDef
+    return ID
+}
+
+func (o *obj) SetServiceDependencyDefs(servicedependencyDef def, idx int) string {
+    ID := strconv.Itoa(idx)
+    o.servicedependencyDefs[ID] = servicedependencyDef
+    return ID
 }
 
-func (o *obj) SetHostDependencyDefs(hostdependencyDef def, idx int) {
-    o.hostdependencyDefs[string(idx)] = hostdependencyDef
+func (o *obj) SetHostEscalationDefs(hostescalationDef def, idx int) string {
+    ID := strconv.Itoa(idx)
+    o.hostescalationDefs[ID] = hostescalationDef
+    return ID
 }
 
-func (o *obj) SetServiceDependencyDefs(servicedependencyDef def, idx int) {
-    o.servicedependencyDefs[string(idx)] = servicedependencyDef
+func (o *obj) SetServiceEscalationDefs(serviceescalationDef def, idx int) string {
+    ID := strconv.Itoa(idx)
+    o.serviceescalationDefs[ID] = serviceescalationDef
+    return ID
 }
 
 func (o *hostgroupOffset) SetEnabledDisabledHostgroups() {
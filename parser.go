@@ -58,6 +58,16 @@ func parseArgs(s []string) *[]string {
     return &newArgs
 }
 
+// get command positional arguments, parseArgs join them with the command name e.g. "add,host"
+func positionalArgs(args []string, cmd *flag.FlagSet) []string {
+    pos := []string{}
+    pos = append(pos, strings.Split(args[1], ",")[1:]...)
+    for _, arg := range cmd.Args() {
+        pos = append(pos, strings.Split(arg, ",")...)
+    }
+    return pos
+}
+
 type commandState struct {
     manditoryArgs           []flag.Flag
     optionalArgs            []flag.Flag
@@ -148,6 +158,37 @@ func formatUsage(cmd *flag.FlagSet){
             fmt.Fprintf(cmd.Output(), "Usage: %v show <optional arguments> [flags...] \n", os.Args[0])
         }else if cmd.Name() == "delete" {
             fmt.Fprintf(cmd.Output(), "Usage: %v delete <optional argument> [flags...] \n", os.Args[0])
+            fmt.Fprintf(cmd.Output(), "       %v delete <object type> <name>... [--cascade] [flags...] \n", os.Args[0])
+            fmt.Fprintf(cmd.Output(), "object types: hostgroup, servicegroup, hosttemplate, servicetemplate, contacttemplate, contact, contactgroup, command, timeperiod\n")
+        }else if cmd.Name() == "add" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v add host <--like <hostname>|--template <template>> <--host <hostname> --address <address>|--file <file>> [flags...] \n", os.Args[0])
+            fmt.Fprintf(cmd.Output(), "       %v add service --template <template> --description <service_description> <--host|--hostgroup|--servicegroup> <name> [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "modify" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v modify <object type> <--name <name>|--query <attr=value>> <--set|--unset|--append|--remove|--replace> <attr=value> [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "rename" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v rename <object type> <old name> <new name> [flags...] \n", os.Args[0])
+            fmt.Fprintf(cmd.Output(), "object types: host, service (service_description), hostgroup, servicegroup, hosttemplate, servicetemplate, contacttemplate, command, contact, contactgroup\n")
+        }else if cmd.Name() == "hostgroup" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v hostgroup <add-member|remove-member> <hostgroup> <hostname>... [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "import" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v import --file <inventory.csv|inventory.json> [--template <template>] [--map <column=attr>] [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "reconcile" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v reconcile --file <inventory.csv|inventory.json> [--map <column=attr>] [--apply --template <template>] [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "blueprint" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v blueprint <list|show <name>|apply <name>> [--var <name=value>] [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "prune" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v prune [--types <object type>] [--apply] [flags...] \n", os.Args[0])
+            fmt.Fprintf(cmd.Output(), "object types: service, hostgroup, hosttemplate, servicetemplate, contacttemplate, command, contact\n")
+        }else if cmd.Name() == "apply" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v apply <plan.json> [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "undo" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v undo [list|<id>] [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "history" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v history <object type> <name>... [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "tree" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v tree <--host|--service|--contact|--template> <name> [flags...] \n", os.Args[0])
+        }else if cmd.Name() == "expand" {
+            fmt.Fprintf(cmd.Output(), "Usage: %v expand -h <hostname> [--service <service_description>] [flags...] \n", os.Args[0])
         }
 
         // required arguments
@@ -189,12 +230,38 @@ func topLevelUsage() {
     cmdSearch   := flag.Flag{Name:"search", Usage:"find services and hostgroups that belong to a specific host"}
     cmdShow     := flag.Flag{Name:"show", Usage:"show Nagios object definition"}
     cmdDelete   := flag.Flag{Name:"delete", Usage:"delete Nagios object definition/association"}
+    cmdAdd      := flag.Flag{Name:"add", Usage:"add Nagios object definition/association"}
+    cmdModify   := flag.Flag{Name:"modify", Usage:"modify attributes of Nagios object definitions matching a name/query"}
+    cmdRename   := flag.Flag{Name:"rename", Usage:"rename Nagios object and every reference to it"}
+    cmdHostgroup := flag.Flag{Name:"hostgroup", Usage:"add/remove host(s) to/from a hostgroup wherever the membership is defined"}
+    cmdImport   := flag.Flag{Name:"import", Usage:"add/update hosts from a csv/json inventory"}
+    cmdReconcile := flag.Flag{Name:"reconcile", Usage:"compare hosts with a csv/json inventory (missing, stale and mismatched hosts)"}
+    cmdBlueprint := flag.Flag{Name:"blueprint", Usage:"list, show and apply parameterized host/service blueprints"}
+    cmdPrune    := flag.Flag{Name:"prune", Usage:"report unused templates, empty hostgroups, hostless services and orphaned commands/contacts, optionally delete them"}
+    cmdApply    := flag.Flag{Name:"apply", Usage:"apply a plan saved with --plan-out, refused if the config files changed since"}
+    cmdUndo     := flag.Flag{Name:"undo", Usage:"revert the last applied change set or a change set of the journal"}
+    cmdHistory  := flag.Flag{Name:"history", Usage:"show the git commits that touched the definition of a Nagios object"}
+    cmdTree     := flag.Flag{Name:"tree", Usage:"show template inheritance tree of Nagios object/template"}
+    cmdExpand   := flag.Flag{Name:"expand", Usage:"expand host/service check_command into the command line Nagios will run"}
     fmt.Fprintf(os.Stderr, "EzNagios is a tool for managing Nagios config files\n\n")
     fmt.Fprintf(os.Stderr, "Usage: %v <command> [arguments]\n", os.Args[0])
     fmt.Fprintf(os.Stderr, "\ncommands:\n")
     fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdSearch, maxFlagLen, ""))
     fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdShow, maxFlagLen, ""))
     fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdDelete, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdAdd, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdModify, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdRename, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdHostgroup, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdImport, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdReconcile, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdBlueprint, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdPrune, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdApply, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdUndo, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdHistory, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdTree, maxFlagLen, ""))
+    fmt.Fprintf(os.Stderr, "%v", *prettyUsage(cmdExpand, maxFlagLen, ""))
     fmt.Fprintf(os.Stderr, "\nUse \"eznagios <command>\" for more information about a command.\n")
 }
 
@@ -243,10 +310,57 @@ func loadEznagiosConfig() map[string]interface{}{
     defaultFlags["verbose"] = false
     defaultFlags["pretty"] = false
     defaultFlags["dryrun"] = false
+    defaultFlags["placement"] = "template"
+    defaultFlags["membership"] = "members"
+    defaultFlags["blueprints"] = ""
+    defaultFlags["backups"] = ""
+    defaultFlags["lock_timeout"] = 30
+    defaultFlags["git_commit"] = true
+    defaultFlags["git_branch"] = false
+    defaultFlags["verify_cmd"] = ""
+    defaultFlags["nagios_cfg"] = "nagios.cfg"
+    defaultFlags["protected_hosts"] = []string{}
+    defaultFlags["protected_hostgroups"] = []string{}
+    defaultFlags["protected_templates"] = []string{}
+    defaultFlags["max_delete_hosts"] = 10
+    defaultFlags["max_delete_defs"] = 50
 
     // load default flags from eznagios config file
-    if _, set := loadedFlags["path"]; set {
-        defaultFlags["path"] = loadedFlags["path"]
+    if val, set := loadedFlags["path"].(string); set {
+        defaultFlags["path"] = val
+    }
+    if val, set := loadedFlags["placement"].(string); set && val != "" {
+        defaultFlags["placement"] = val
+    }
+    if val, set := loadedFlags["membership"].(string); set && val != "" {
+        defaultFlags["membership"] = val
+    }
+    if val, set := loadedFlags["blueprints"].(string); set {
+        defaultFlags["blueprints"] = val
+    }
+    if val, set := loadedFlags["backups"].(string); set {
+        defaultFlags["backups"] = val
+    }
+    if val, set := loadedFlags["verify_cmd"].(string); set {
+        defaultFlags["verify_cmd"] = val
+    }
+    if val, set := loadedFlags["nagios_cfg"].(string); set && val != "" {
+        defaultFlags["nagios_cfg"] = val
+    }
+    for _, list := range []string{"protected_hosts", "protected_hostgroups", "protected_templates"} {
+        if vals, set := loadedFlags[list].([]interface{}); set {
+            names := []string{}
+            for _, v := range vals {
+                names = append(names, fmt.Sprintf("%v", v))
+            }
+            defaultFlags[list] = names
+        }
+    }
+    // json numbers are decoded as float64
+    for _, limit := range []string{"max_delete_hosts", "max_delete_defs", "lock_timeout"} {
+        if val, set := loadedFlags[limit].(float64); set {
+            defaultFlags[limit] = int(val)
+        }
     }
     if _, set := loadedFlags["verbose"]; set {
         defaultFlags["verbose"] = loadedFlags["verbose"]
@@ -263,6 +377,12 @@ func loadEznagiosConfig() map[string]interface{}{
     if _, set := loadedFlags["dryrun"]; set {
         defaultFlags["dryrun"] = loadedFlags["dryrun"]
     }
+    if val, set := loadedFlags["git_commit"].(bool); set {
+        defaultFlags["git_commit"] = val
+    }
+    if val, set := loadedFlags["git_branch"].(bool); set {
+        defaultFlags["git_branch"] = val
+    }
     return defaultFlags
 }
 
@@ -274,6 +394,14 @@ func setActualFlags(fs *flag.FlagSet) map[string]interface{} {
     bflags["pretty"]    = struct{}{}
     bflags["warn"]      = struct{}{}
     bflags["dryrun"]    = struct{}{}
+    bflags["reverse"]   = struct{}{}
+    bflags["apply"]     = struct{}{}
+    bflags["cascade"]   = struct{}{}
+    bflags["force"]     = struct{}{}
+    bflags["yes"]       = struct{}{}
+    bflags["patch"]     = struct{}{}
+    bflags["git-commit"] = struct{}{}
+    bflags["git-branch"] = struct{}{}
     visited := make(map[string]interface{})
     fs.Visit(func(f *flag.Flag){
         visited[f.Name] = f.Value
@@ -304,16 +432,60 @@ func setEnabledFlags(visited map[string]interface{}) (attrVal, map[string]interf
     cval, cf := visited["color"]
     pval, pf := visited["pretty"]
     dval, df := visited["dryrun"]
+    fval, ff := visited["force"]
+    yval, yf := visited["yes"]
+    patchval, patchf := visited["patch"]
 
     if sd {
         enabled["path"] = sval
     }else if defaultFlags["path"].(string) != "" {
-        enabled["path"] = defaultFlags["path"]
+        enabled["path"] = []string{defaultFlags["path"].(string)}
     }else{
         err := errors.New("Please set the default path to nagios configs using 'set' command")
         fmt.Println(&parsingError{err})
-        os.Exit(1)
+        exit(1)
+    }
+
+    // new object placement rule
+    enabled["placement"] = defaultFlags["placement"]
+    if val, set := visited["target"]; set {
+        enabled["placement"] = val.([]string)[0]
+    }
+    // hostgroup membership house style
+    enabled["membership"] = defaultFlags["membership"]
+    if val, set := visited["style"]; set {
+        enabled["membership"] = val.([]string)[0]
+    }
+    // blueprints directory, default is next to the eznagios config file
+    enabled["blueprints"] = defaultFlags["blueprints"]
+    if val, set := visited["dir"]; set {
+        enabled["blueprints"] = val.([]string)[0]
+    }else if defaultFlags["blueprints"].(string) == "" {
+        enabled["blueprints"] = path.Join(path.Dir(setConfigFile()), "blueprints")
+    }
+    // backups of the written config files, default is next to the eznagios config file
+    enabled["backups"] = defaultFlags["backups"]
+    if defaultFlags["backups"].(string) == "" {
+        enabled["backups"] = path.Join(path.Dir(setConfigFile()), "backups")
+    }
+    // save the change set as a plan instead of writing it
+    if val, set := visited["plan-out"]; set {
+        enabled["plan_out"] = strings.Join(val.([]string), ",")
     }
+    // json summary of the change set
+    if val, set := visited["summary-out"]; set {
+        enabled["summary_out"] = strings.Join(val.([]string), ",")
+    }
+    // protected objects and deletion limits
+    for _, key := range []string{"protected_hosts", "protected_hostgroups", "protected_templates", "max_delete_hosts", "max_delete_defs", "lock_timeout"} {
+        enabled[key] = defaultFlags[key]
+    }
+    // git commits of the written config files
+    enabled["git_commit"] = defaultFlags["git_commit"]
+    enabled["git_branch"] = defaultFlags["git_branch"]
+    // nagios verification of the changes before they are written
+    enabled["verify_cmd"] = defaultFlags["verify_cmd"]
+    enabled["nagios_cfg"] = defaultFlags["nagios_cfg"]
 
     // optional boolean flags
     if vf && vval.(bool) || !vf && defaultFlags["verbose"].(bool) {
@@ -336,6 +508,19 @@ func setEnabledFlags(visited map[string]interface{}) (attrVal, map[string]interf
         enabled["dryrun"] = true
         enabledBools = append(enabledBools, "dryrun")
     }
+    // force is never a default
+    if ff && fval.(bool) {
+        enabled["force"] = true
+        enabledBools = append(enabledBools, "force")
+    }
+    if yf && yval.(bool) {
+        enabled["yes"] = true
+        enabledBools = append(enabledBools, "yes")
+    }
+    if patchf && patchval.(bool) {
+        enabled["patch"] = true
+        enabledBools = append(enabledBools, "patch")
+    }
 
     return enabledBools, enabled
 }
@@ -343,21 +528,46 @@ func setEnabledFlags(visited map[string]interface{}) (attrVal, map[string]interf
 func main() {
 //    hostVal := multiValues{}
     args := []string{}
-    excludedDirs := []string{".git", "libexec", "timeperiods.cfg", "servicegroups.cfg"}
+    // servicegroups.cfg and timeperiods.cfg are loaded too, servicegroups and timeperiods are referenced by changes
+    excludedDirs := []string{".git", "libexec"}
 
     // eznagios commands
     searchCommand   := flag.NewFlagSet ("search", flag.ExitOnError)
     showCommand     := flag.NewFlagSet ("show", flag.ExitOnError)
     deleteCommand   := flag.NewFlagSet ("delete", flag.ExitOnError)
     addCommand      := flag.NewFlagSet ("add", flag.ExitOnError)
+    modifyCommand   := flag.NewFlagSet ("modify", flag.ExitOnError)
+    renameCommand   := flag.NewFlagSet ("rename", flag.ExitOnError)
+    hostgroupCommand := flag.NewFlagSet ("hostgroup", flag.ExitOnError)
+    importCommand   := flag.NewFlagSet ("import", flag.ExitOnError)
+    reconcileCommand := flag.NewFlagSet ("reconcile", flag.ExitOnError)
+    blueprintCommand := flag.NewFlagSet ("blueprint", flag.ExitOnError)
+    pruneCommand    := flag.NewFlagSet ("prune", flag.ExitOnError)
+    applyCommand    := flag.NewFlagSet ("apply", flag.ExitOnError)
+    undoCommand     := flag.NewFlagSet ("undo", flag.ExitOnError)
+    historyCommand  := flag.NewFlagSet ("history", flag.ExitOnError)
     setCommand      := flag.NewFlagSet ("set", flag.ExitOnError)
+    treeCommand     := flag.NewFlagSet ("tree", flag.ExitOnError)
+    expandCommand   := flag.NewFlagSet ("expand", flag.ExitOnError)
 
     // custom usage for each command
     searchCommand.Usage = func(){formatUsage(searchCommand)}
     showCommand.Usage   = func(){formatUsage(showCommand)}
     deleteCommand.Usage = func(){formatUsage(deleteCommand)}
     addCommand.Usage    = func(){formatUsage(addCommand)}
+    modifyCommand.Usage = func(){formatUsage(modifyCommand)}
+    renameCommand.Usage = func(){formatUsage(renameCommand)}
+    hostgroupCommand.Usage = func(){formatUsage(hostgroupCommand)}
+    importCommand.Usage = func(){formatUsage(importCommand)}
+    reconcileCommand.Usage = func(){formatUsage(reconcileCommand)}
+    blueprintCommand.Usage = func(){formatUsage(blueprintCommand)}
+    pruneCommand.Usage  = func(){formatUsage(pruneCommand)}
+    applyCommand.Usage  = func(){formatUsage(applyCommand)}
+    undoCommand.Usage   = func(){formatUsage(undoCommand)}
+    historyCommand.Usage = func(){formatUsage(historyCommand)}
     setCommand.Usage    = func(){formatUsage(setCommand)}
+    treeCommand.Usage   = func(){formatUsage(treeCommand)}
+    expandCommand.Usage = func(){formatUsage(expandCommand)}
 
     // associate flags with their corsponding subcommand
     // search command
@@ -379,18 +589,188 @@ func main() {
     setCommand.Bool("color", false, "show colorful output by default")
     setCommand.Bool("verbose", false, "show verbose output by default")
     setCommand.Bool("warn", false, "show warning message by default")
+    setCommand.String("placement", "", "config file of new objects: 'template' (next to objects using the same template) or a path pattern relative to nagios config directory e.g. hosts/{host_name}.cfg, {template}/{hostgroup}.cfg")
+    setCommand.String("membership", "", "where hostgroup membership is added: 'members' (hostgroup members) or 'hostgroups' (host hostgroups)")
+    setCommand.String("blueprints", "", "set the default blueprints directory")
+    setCommand.String("backups", "", "set the directory of the timestamped backups of written config files")
+    setCommand.String("protect-hosts", "", "hosts that delete and modify refuse to touch, names or regex. Empty value clear the list")
+    setCommand.String("protect-hostgroups", "", "hostgroups that delete and modify refuse to touch, names or regex. Empty value clear the list")
+    setCommand.String("protect-templates", "", "templates that delete and modify refuse to touch, names or regex. Empty value clear the list")
+    setCommand.Int("max-delete-hosts", 10, "max number of hosts a single run may delete, 0 means no limit")
+    setCommand.Int("max-delete-defs", 50, "max number of object definitions a single run may delete, 0 means no limit")
+    setCommand.Int("lock-timeout", 30, "seconds to wait for another eznagios run to release the config directory lock")
+    setCommand.String("verify-cmd", "", "command that verifies the config before changes are written, {cfg} is replaced by the staged nagios main config e.g. '/usr/sbin/nagios -v {cfg}'. Empty value disables the verification")
+    setCommand.String("nagios-cfg", "", "nagios main config used by the verification, relative to the nagios config directory unless absolute. Default nagios.cfg")
+    setCommand.Bool("git-commit", true, "commit every applied change set when the nagios config directory is in a git work tree")
+    setCommand.Bool("git-branch", false, "create a branch eznagios/<change set>-<command> at the commit of every applied change set, the work tree stays on its branch")
 
     // delete command
     deleteCommand.String("host", "", "hostname, Multiple hosts should be separated by comma/space. Support regex ")
     deleteCommand.String("src", "", "path to nagios configs directory")
     deleteCommand.String("file", "", "file contains list of hosts")
+    deleteCommand.String("service", "", "service_description, delete only this service from the host(s)")
+    deleteCommand.Bool("cascade", false, "detach the references to the deleted object instead of refusing the deletion")
     deleteCommand.Bool("verbose", false, "show verbose output")
     deleteCommand.Bool("color", false, "show colorful output")
-    deleteCommand.Bool("dryrun", false, "perform deletion but dont apply changes")
+    deleteCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    deleteCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    deleteCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    deleteCommand.Bool("yes", false, "apply the changes without confirmation")
+    deleteCommand.Bool("force", false, "allow the run to delete more than the max-delete-hosts/max-delete-defs limits or to leave hosts and services without required attributes")
+
+    // add command
+    addCommand.String("like", "", "existing hostname to clone the new host(s) from")
+    addCommand.String("host", "", "new hostname (add host) or host of the new service (add service), Multiple hosts should be separated by comma/space")
+    addCommand.String("address", "", "new host address, one address for every new host")
+    addCommand.String("alias", "", "new host alias, one alias for every new host")
+    addCommand.String("template", "", "host/service template to create the new object(s) from")
+    addCommand.String("file", "", "file contains list of new hosts, one 'host_name address [alias]' per line or csv with header 'host_name,address,alias,<extra attributes>...'")
+    addCommand.String("hostgroups", "", "hostgroups of the new host(s)")
+    addCommand.String("description", "", "service_description of the new service")
+    addCommand.String("command", "", "check_command of the new service including its arguments e.g. check_http!-p 8080")
+    addCommand.String("hostgroup", "", "hostgroup of the new service")
+    addCommand.String("servicegroup", "", "servicegroup of the new service, the service is added to the servicegroup hosts if no host/hostgroup is given")
+    addCommand.String("set", "", "extra attributes of the new object(s) e.g. notes=web,_SNMP_COMMUNITY=public")
+    addCommand.String("target", "", "config file placement rule of the new object(s), override the default placement rule")
+    addCommand.String("src", "", "path to nagios configs directory")
+    addCommand.Bool("verbose", false, "show verbose output")
+    addCommand.Bool("color", false, "show colorful output")
+    addCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    addCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    addCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    addCommand.Bool("yes", false, "apply the changes without confirmation")
+
+    // modify command
+    modifyCommand.String("name", "", "name of the objects to modify, Multiple names should be separated by comma. Support regex")
+    modifyCommand.String("query", "", "select objects by attribute: attr=value, attr!=value, attr~regex or attr (defined). Multiple conditions should be separated by comma")
+    modifyCommand.String("set", "", "set attribute value e.g. notification_interval=30")
+    modifyCommand.String("unset", "", "remove attribute(s) from the object definition")
+    modifyCommand.String("append", "", "append value(s) to a list attribute e.g. contact_groups=admins")
+    modifyCommand.String("remove", "", "remove value(s) from a list attribute e.g. contact_groups=admins")
+    modifyCommand.String("replace", "", "replace a value, old:new e.g. check_period=workhours:24x7. check_command and other single values replace whole '!' separated parts e.g. check_command=check_http:check_https")
+    modifyCommand.String("src", "", "path to nagios configs directory")
+    modifyCommand.Bool("verbose", false, "show verbose output")
+    modifyCommand.Bool("color", false, "show colorful output")
+    modifyCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    modifyCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    modifyCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    modifyCommand.Bool("yes", false, "apply the changes without confirmation")
+    modifyCommand.Bool("force", false, "allow the run to delete more than the max-delete-hosts/max-delete-defs limits or to leave hosts and services without required attributes")
+
+    // rename command
+    renameCommand.String("src", "", "path to nagios configs directory")
+    renameCommand.Bool("color", false, "show colorful output")
+    renameCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    renameCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    renameCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    renameCommand.Bool("yes", false, "apply the changes without confirmation")
+
+    // hostgroup command
+    hostgroupCommand.String("style", "", "where membership is added: 'members' (hostgroup members) or 'hostgroups' (host hostgroups), override the default style")
+    hostgroupCommand.String("template-action", "", "remove membership that comes from a host template: 'exclude' (add '!host' to hostgroup members, default) or 'detach' (move the host off the template)")
+    hostgroupCommand.String("src", "", "path to nagios configs directory")
+    hostgroupCommand.Bool("verbose", false, "show how the host is a member of the hostgroup")
+    hostgroupCommand.Bool("color", false, "show colorful output")
+    hostgroupCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    hostgroupCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    hostgroupCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    hostgroupCommand.Bool("yes", false, "apply the changes without confirmation")
+    hostgroupCommand.Bool("force", false, "allow the run to delete more than the max-delete-hosts/max-delete-defs limits or to leave hosts and services without required attributes")
+
+    // import command
+    importCommand.String("file", "", "inventory file, csv with a header line or json array of objects")
+    importCommand.String("map", "", "map inventory columns to host attributes e.g. hostname=host_name,ip=address,role=hostgroups,rack=_RACK (use '-' to ignore a column)")
+    importCommand.String("template", "", "host template of the new hosts, a column mapped to 'use' override it")
+    importCommand.String("target", "", "config file placement rule of the new host(s), override the default placement rule")
+    importCommand.String("style", "", "where hostgroup membership of existing hosts is added: 'members' or 'hostgroups'")
+    importCommand.String("src", "", "path to nagios configs directory")
+    importCommand.Bool("verbose", false, "show verbose output")
+    importCommand.Bool("color", false, "show colorful output")
+    importCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    importCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    importCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    importCommand.Bool("yes", false, "apply the changes without confirmation")
+
+    // reconcile command
+    reconcileCommand.String("file", "", "inventory file, csv with a header line or json array of objects")
+    reconcileCommand.String("map", "", "map inventory columns to host attributes e.g. hostname=host_name,ip=address,rack=_RACK")
+    reconcileCommand.String("attrs", "", "attributes to compare beside address e.g. alias,_RACK")
+    reconcileCommand.String("scope", "", "regex matching the whole host_name of the nagios hosts covered by the inventory (default all hosts)")
+    reconcileCommand.String("hostgroup", "", "hostgroup of the nagios hosts covered by the inventory")
+    reconcileCommand.String("template", "", "host template of the missing hosts added by --apply, a column mapped to 'use' override it")
+    reconcileCommand.String("target", "", "config file placement rule of the missing hosts, override the default placement rule")
+    reconcileCommand.String("src", "", "path to nagios configs directory")
+    reconcileCommand.Bool("apply", false, "add missing hosts and delete stale hosts")
+    reconcileCommand.Bool("verbose", false, "show verbose output")
+    reconcileCommand.Bool("color", false, "show colorful output")
+    reconcileCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    reconcileCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    reconcileCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    reconcileCommand.Bool("yes", false, "apply the changes without confirmation")
+    reconcileCommand.Bool("force", false, "allow the run to delete more than the max-delete-hosts/max-delete-defs limits or to leave hosts and services without required attributes")
+
+    // blueprint command
+    blueprintCommand.String("var", "", "blueprint variables e.g. host_name=web03,address=10.0.0.13")
+    blueprintCommand.String("dir", "", "blueprints directory, override the default blueprints directory")
+    blueprintCommand.String("target", "", "config file placement rule of the new object(s), override the default placement rule")
+    blueprintCommand.String("src", "", "path to nagios configs directory")
+    blueprintCommand.Bool("verbose", false, "show the added object definitions")
+    blueprintCommand.Bool("color", false, "show colorful output")
+    blueprintCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    blueprintCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    blueprintCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    blueprintCommand.Bool("yes", false, "apply the changes without confirmation")
+
+    // prune command
+    pruneCommand.String("types", "", "object types to look for, required with --apply e.g. hosttemplate,command (default all)")
+    pruneCommand.String("src", "", "path to nagios configs directory")
+    pruneCommand.Bool("apply", false, "delete the unused objects")
+    pruneCommand.Bool("verbose", false, "show verbose output")
+    pruneCommand.Bool("color", false, "show colorful output")
+    pruneCommand.String("summary-out", "", "write a json summary of the change set to a file")
+    pruneCommand.String("plan-out", "", "save the change set as a plan file to be applied later with the apply command, nothing is written")
+    pruneCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    pruneCommand.Bool("yes", false, "apply the changes without confirmation")
+    pruneCommand.Bool("force", false, "allow the run to delete more than the max-delete-hosts/max-delete-defs limits or to leave hosts and services without required attributes")
+
+    // apply command
+    applyCommand.String("src", "", "path to nagios configs directory, default is the directory of the plan")
+    applyCommand.Bool("color", false, "show colorful output")
+    applyCommand.Bool("dryrun", false, "show the plan changes as unified diff but dont apply them")
+    applyCommand.Bool("yes", false, "apply the plan without confirmation")
+    applyCommand.Bool("force", false, "allow the plan to delete more than the max-delete-hosts/max-delete-defs limits")
+
+    // undo command
+    undoCommand.Bool("color", false, "show colorful output")
+    undoCommand.Bool("dryrun", false, "show the changes as unified diff but dont apply them")
+    undoCommand.Bool("yes", false, "undo without confirmation")
+
+    // history command
+    historyCommand.String("src", "", "path to nagios configs directory")
+    historyCommand.Bool("color", false, "show colorful output")
+    historyCommand.Bool("patch", false, "show how each commit changed the definition")
+
+    // tree command
+    treeCommand.String("host", "", "hostname to show its template inheritance tree, Multiple hosts should be separated by comma/space")
+    treeCommand.String("service", "", "service description to show its template inheritance tree, use with --host to select the host service")
+    treeCommand.String("contact", "", "contact name to show its template inheritance tree")
+    treeCommand.String("template", "", "template name to show its inheritance tree")
+    treeCommand.String("type", "", "template object type (host, service, contact). Default all types")
+    treeCommand.String("src", "", "path to nagios configs directory")
+    treeCommand.Bool("reverse", false, "list every template and object inheriting from --template")
+    treeCommand.Bool("color", false, "show colorful output")
+
+    // expand command
+    expandCommand.String("host", "", "hostname, Multiple hosts should be separated by comma/space")
+    expandCommand.String("service", "", "service description, expand host check_command if not set")
+    expandCommand.String("resource", "", "nagios resource file that define $USERn$ macros, if not in nagios configs directory")
+    expandCommand.String("src", "", "path to nagios configs directory")
+    expandCommand.Bool("verbose", false, "show the value of every substituted macro")
+    expandCommand.Bool("color", false, "show colorful output")
     if len(os.Args) < 2 {
 //        fmt.Printf("Expected one of these subcommands %v\n", subCommandList)
         topLevelUsage()
-        os.Exit(1)
+        exit(1)
     }else {
         args = *parseArgs(os.Args)
     }
@@ -404,11 +784,35 @@ func main() {
         addCommand.Parse(args[2:])
     case "delete":
         deleteCommand.Parse(args[2:])
+    case "modify":
+        modifyCommand.Parse(args[2:])
+    case "rename":
+        renameCommand.Parse(args[2:])
+    case "hostgroup":
+        hostgroupCommand.Parse(args[2:])
+    case "import":
+        importCommand.Parse(args[2:])
+    case "reconcile":
+        reconcileCommand.Parse(args[2:])
+    case "blueprint":
+        blueprintCommand.Parse(args[2:])
+    case "prune":
+        pruneCommand.Parse(args[2:])
+    case "apply":
+        applyCommand.Parse(args[2:])
+    case "undo":
+        undoCommand.Parse(args[2:])
+    case "history":
+        historyCommand.Parse(args[2:])
     case "set":
         setCommand.Parse(args[2:])
+    case "tree":
+        treeCommand.Parse(args[2:])
+    case "expand":
+        expandCommand.Parse(args[2:])
     default:
         fmt.Println("Error: Unrecognized command")
-        os.Exit(1)
+        exit(1)
     }
 
     if setCommand.Parsed() {
@@ -417,9 +821,64 @@ func main() {
         eznagiosConfigs := loadEznagiosConfig()
         configFile      := setConfigFile()
 
-        if _, set := visited["src"]; set {
-            eznagiosConfigs["path"] = visited["path"]
-            fmt.Printf("%vEzNagiosConfig:%v set '%v' as the default path to nagios-configs \n", Green, RST, visited["path"])
+        if val, set := visited["src"]; set {
+            eznagiosConfigs["path"] = val.([]string)[0]
+            fmt.Printf("%vEzNagiosConfig:%v set '%v' as the default path to nagios-configs \n", Green, RST, eznagiosConfigs["path"])
+        }
+
+        if val, set := visited["placement"]; set {
+            eznagiosConfigs["placement"] = val.([]string)[0]
+            fmt.Printf("%vEzNagiosConfig:%v set '%v' as the default placement rule of new objects\n", Green, RST, eznagiosConfigs["placement"])
+        }
+
+        if val, set := visited["membership"]; set {
+            eznagiosConfigs["membership"] = val.([]string)[0]
+            fmt.Printf("%vEzNagiosConfig:%v set '%v' as the default hostgroup membership style\n", Green, RST, eznagiosConfigs["membership"])
+        }
+
+        if val, set := visited["blueprints"]; set {
+            eznagiosConfigs["blueprints"] = val.([]string)[0]
+            fmt.Printf("%vEzNagiosConfig:%v set '%v' as the default blueprints directory\n", Green, RST, eznagiosConfigs["blueprints"])
+        }
+
+        if val, set := visited["backups"]; set {
+            eznagiosConfigs["backups"] = val.([]string)[0]
+            fmt.Printf("%vEzNagiosConfig:%v set '%v' as the backups directory\n", Green, RST, eznagiosConfigs["backups"])
+        }
+
+        if val, set := visited["verify-cmd"]; set {
+            eznagiosConfigs["verify_cmd"] = strings.Join(val.([]string), ",")
+            if eznagiosConfigs["verify_cmd"] == "" {
+                fmt.Printf("%vEzNagiosConfig:%v disable the verification of changes\n", Green, RST)
+            }else {
+                fmt.Printf("%vEzNagiosConfig:%v set '%v' as the verification command\n", Green, RST, eznagiosConfigs["verify_cmd"])
+            }
+        }
+
+        if val, set := visited["nagios-cfg"]; set {
+            eznagiosConfigs["nagios_cfg"] = val.([]string)[0]
+            fmt.Printf("%vEzNagiosConfig:%v set '%v' as the nagios main config\n", Green, RST, eznagiosConfigs["nagios_cfg"])
+        }
+
+        protectFlags := [][2]string{{"protect-hosts", "protected_hosts"}, {"protect-hostgroups", "protected_hostgroups"}, {"protect-templates", "protected_templates"}}
+        for _, p := range protectFlags {
+            if val, set := visited[p[0]]; set {
+                eznagiosConfigs[p[1]] = protectedNames(val.([]string))
+                fmt.Printf("%vEzNagiosConfig:%v set %v to %v\n", Green, RST, p[1], eznagiosConfigs[p[1]])
+            }
+        }
+
+        limitFlags := [][2]string{{"max-delete-hosts", "max_delete_hosts"}, {"max-delete-defs", "max_delete_defs"}, {"lock-timeout", "lock_timeout"}}
+        for _, l := range limitFlags {
+            if val, set := visited[l[0]]; set {
+                max, err := strconv.Atoi(val.([]string)[0]); if err != nil || max < 0 {
+                    err := fmt.Errorf("--%v expects a positive number", l[0])
+                    fmt.Println(&parsingError{err})
+                    exit(1)
+                }
+                eznagiosConfigs[l[1]] = max
+                fmt.Printf("%vEzNagiosConfig:%v set %v to %v\n", Green, RST, l[1], max)
+            }
         }
 
         if val, set := visited["color"]; set {
@@ -439,6 +898,18 @@ func main() {
                 fmt.Printf("%vEzNagiosConfig:%v unset verbose output as the default output\n", Green, RST)
             }
         }
+        gitFlags := [][3]string{{"git-commit", "git_commit", "commits of applied change sets"}, {"git-branch", "git_branch", "a branch for every applied change set"}}
+        for _, g := range gitFlags {
+            if val, set := visited[g[0]]; set {
+                eznagiosConfigs[g[1]] = val
+                if val.(bool) {
+                    fmt.Printf("%vEzNagiosConfig:%v enable %v\n", Green, RST, g[2])
+                }else {
+                    fmt.Printf("%vEzNagiosConfig:%v disable %v\n", Green, RST, g[2])
+                }
+            }
+        }
+
         if val, set := visited["warn"]; set {
             eznagiosConfigs["warn"] = val
             if val.(bool) {
@@ -451,12 +922,12 @@ func main() {
         jdata, err := json.MarshalIndent(eznagiosConfigs, "", " "); if err != nil {
             err := errors.New("Failed to update eznagios config file")
             fmt.Println(err)
-            os.Exit(1)
+            exit(1)
         }
         // write configs to a file
         jfile, err :=  os.Create(configFile); if err != nil {
             fmt.Println(err)
-            os.Exit(1)
+            exit(1)
         }
         jfile.Write(jdata)
     }
@@ -471,7 +942,7 @@ func main() {
         if !sh && !sf {
             err := errors.New("--host or --file option is required")
             fmt.Println(&parsingError{err})
-            os.Exit(1)
+            exit(1)
         }
 
         // load nagios data
@@ -525,41 +996,173 @@ func main() {
 
         hval, sh := visited["host"]
         _, sf := visited["file"]
+        pos := positionalArgs(args, deleteCommand)
         // required flags
-        if !sh && !sf {
+        if !sh && !sf && len(pos) == 0 {
             err := errors.New("--host or --file option is required")
             fmt.Println(&parsingError{err})
-            os.Exit(1)
-        }
-        // load nagios data
-        objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
-        // parse host arg
-        knownHosts, unknownHosts, noRegex := parseRegex(hval.([]string), &objDefs.hostDefs)
-        for _, h := range knownHosts {
-            // search for host object
-            host := findHost(&objDefs.hostDefs, &objDefs.hostTempDefs, h)
-            // serach hostgroups association
-            hostgroups := findHostGroups(&objDefs.hostgroupDefs, &objDefs.hostTempDefs, host)
-            // search services association
-            services := findServices(&objDefs.serviceDefs, &objDefs.serviceTempDefs, hostgroups, h)
-            // perform deletion
-            deleteHost(&objDefs.hostDefs, &objDefs.hostTempDefs, &host, bflags)
-            deleteHostgroup(objDefs, &hostgroups, h, bflags)
-            deleteService(objDefs, &services, hostgroups.deleted, h, bflags)
+            exit(1)
         }
-        for _, v := range unknownHosts {
-            err := errors.New("host not found")
-            fmt.Println(&NotFoundError{err, "Warn", v})
+        withConfigLock(enabled, bflags, true, func() {
+            // load nagios data
+            objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            if len(pos) > 0 {
+                // delete other object types by name
+                deleteObjCmd(objDefs, pos, visited, enabled, bflags)
+            } else {
+                // parse host arg
+                knownHosts, unknownHosts, noRegex := parseRegex(hval.([]string), &objDefs.hostDefs)
+                if sval, ss := visited["service"]; ss {
+                    // service_description may contain commas
+                    desc := strings.Join(sval.([]string), ",")
+                    checkProtectedHosts(knownHosts, enabled)
+                    for _, h := range knownHosts {
+                        if !deleteHostService(objDefs, h, desc, bflags) {
+                            err := errors.New("service not found")
+                            fmt.Println(&NotFoundError{err, "Warn", h + " " + desc})
+                        }
+                    }
+                } else {
+                    deleteHosts(objDefs, knownHosts, bflags)
+                }
+                for _, v := range unknownHosts {
+                    err := errors.New("host not found")
+                    fmt.Println(&NotFoundError{err, "Warn", v})
+                }
+                for _, v := range noRegex {
+                    err := errors.New("regex match nothing")
+                    fmt.Println(&NotFoundError{err, "Warn", v})
+                }
+                objDefs.commitChanges(enabled, bflags)
+            }
+        })
+    }
+    if addCommand.Parsed() {
+        visited := setActualFlags(addCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        withConfigLock(enabled, bflags, true, func() {
+            // load nagios data
+            objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            addCmd(objDefs, positionalArgs(args, addCommand), visited, enabled, bflags)
+        })
+    }
+    if modifyCommand.Parsed() {
+        visited := setActualFlags(modifyCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        withConfigLock(enabled, bflags, true, func() {
+            // load nagios data
+            objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            modifyCmd(objDefs, positionalArgs(args, modifyCommand), visited, enabled, bflags)
+        })
+    }
+    if renameCommand.Parsed() {
+        visited := setActualFlags(renameCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        withConfigLock(enabled, bflags, true, func() {
+            // load nagios data
+            objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            renameCmd(objDefs, positionalArgs(args, renameCommand), enabled, bflags)
+        })
+    }
+    if hostgroupCommand.Parsed() {
+        visited := setActualFlags(hostgroupCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        withConfigLock(enabled, bflags, true, func() {
+            // load nagios data
+            objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            hostgroupCmd(objDefs, positionalArgs(args, hostgroupCommand), visited, enabled, bflags)
+        })
+    }
+    if importCommand.Parsed() {
+        visited := setActualFlags(importCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        withConfigLock(enabled, bflags, true, func() {
+            // load nagios data
+            objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            importCmd(objDefs, visited, enabled, bflags)
+        })
+    }
+    if reconcileCommand.Parsed() {
+        visited := setActualFlags(reconcileCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        // reports only read the config directory
+        aval, sa := visited["apply"]
+        withConfigLock(enabled, bflags, sa && aval.(bool), func() {
+            // load nagios data
+            objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            reconcileCmd(objDefs, visited, enabled, bflags)
+        })
+    }
+    if blueprintCommand.Parsed() {
+        visited := setActualFlags(blueprintCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        pos := positionalArgs(args, blueprintCommand)
+        // nagios data is only needed to apply a blueprint
+        apply := len(pos) > 0 && pos[0] == "apply"
+        withConfigLock(enabled, bflags, apply, func() {
+            var objDefs *obj
+            if apply {
+                objDefs = loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            }
+            blueprintCmd(objDefs, pos, visited, enabled, bflags)
+        })
+    }
+    if pruneCommand.Parsed() {
+        visited := setActualFlags(pruneCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        // reports only read the config directory
+        aval, sa := visited["apply"]
+        withConfigLock(enabled, bflags, sa && aval.(bool), func() {
+            // load nagios data
+            objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+            pruneCmd(objDefs, visited, enabled, bflags)
+        })
+    }
+    if applyCommand.Parsed() {
+        visited := setActualFlags(applyCommand)
+        pos := positionalArgs(args, applyCommand)
+        if len(pos) != 1 {
+            err := errors.New("expected 'apply <plan.json>'")
+            fmt.Println(&parsingError{err})
+            exit(1)
         }
-        for _, v := range noRegex {
-            err := errors.New("regex match nothing")
-            fmt.Println(&NotFoundError{err, "Warn", v})
+        p := readPlan(pos[0])
+        // the plan knows the config directory it was made for
+        if _, set := visited["src"]; !set {
+            visited["src"] = []string{p.Path}
         }
-        // write new files
-        WriteFile(&objDefs.hostDefs, "hosts.cfg", "host", bflags)
-        WriteFile(&objDefs.hostgroupDefs, "hostgroups.cfg", "hostgroup", bflags)
-        WriteFile(&objDefs.serviceDefs, "services.cfg", "service", bflags)
-        WriteFile(&objDefs.serviceTempDefs, "servicestemplates.cfg", "servicetemplate", bflags)
+        bflags, enabled := setEnabledFlags(visited)
+        withConfigLock(enabled, bflags, true, func() {
+            applyCmd(p, enabled, bflags)
+        })
+    }
+    if undoCommand.Parsed() {
+        visited := setActualFlags(undoCommand)
+        // undo does not read the nagios configs, the journal knows the config files
+        visited["src"] = []string{""}
+        bflags, enabled := setEnabledFlags(visited)
+        undoCmd(positionalArgs(args, undoCommand), enabled, bflags)
+    }
+    if historyCommand.Parsed() {
+        visited := setActualFlags(historyCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        // load nagios data
+        objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+        historyCmd(objDefs, positionalArgs(args, historyCommand), enabled, bflags)
+    }
+    if treeCommand.Parsed() {
+        visited := setActualFlags(treeCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        // load nagios data
+        objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+        treeCmd(objDefs, visited, bflags)
+    }
+    if expandCommand.Parsed() {
+        visited := setActualFlags(expandCommand)
+        bflags, enabled := setEnabledFlags(visited)
+        // load nagios data
+        objDefs := loadNagiosData(enabled["path"], ".cfg", excludedDirs)
+        expandCmd(objDefs, visited, bflags)
     }
 }
 
@@ -604,13 +1207,19 @@ func parseRegex (s []string, d *defs) ([]string, []string, []string){
 func loadNagiosData(cfg interface{}, fileExt string, excludedDirs []string) *obj {
     // perform serach
     configFiles := findConfFiles(cfg.([]string)[0], ".cfg", excludedDirs)
-    rawData, err := readConfFile(configFiles)
-    if err != nil {
-        panic(fmt.Sprintf("%v", err))
+    objDefs := newObj()
+    objDefs.path = cfg.([]string)[0]
+    for _, configFile := range configFiles {
+        rawData, err := readConfFile([]string{configFile})
+        if err != nil {
+            panic(fmt.Sprintf("%v", err))
+        }
+        // parse nagios config file
+        getObjDefs(objDefs, rawData, configFile)
     }
-    // parse nagios config file
-    objDefs, err := getObjDefs(rawData); if err != nil {
-        panic(fmt.Sprintf("%v", err))
+    if objDefs.numObjDefs() == 0 {
+        err := errors.New("no nagios object definition found")
+        panic(fmt.Sprintf("%v", &NotFoundError{err, "Fatal", cfg.([]string)[0]}))
     }
     return objDefs
 }
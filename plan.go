@@ -0,0 +1,183 @@
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/user"
+    "path/filepath"
+    "strings"
+    "time"
+
+)
+
+// plan file format version
+const planVersion = 1
+
+// change set saved by --plan-out, applied later by the apply command
+type changePlan struct {
+    Version     int                 `json:"version"`
+    Command     string              `json:"command"`
+    User        string              `json:"user"`
+    Created     string              `json:"created"`
+    Path        string              `json:"path"`           // nagios config directory
+    Summary     changeSummary       `json:"summary"`
+    Files       []planFile          `json:"files"`
+}
+
+// config file of a plan, hash of the content the plan was made from and the new content
+type planFile struct {
+    File        string              `json:"file"`           // relative to the nagios config directory
+    Hash        string              `json:"sha256"`         // empty for new config files
+    Content     string              `json:"content"`
+}
+
+// sha256 of a config file content
+func contentHash(data string) string {
+    sum := sha256.Sum256([]byte(data))
+    return hex.EncodeToString(sum[:])
+}
+
+// save the change set of the run as a plan
+func savePlan(o *obj, fileName string, summary changeSummary) {
+    p := changePlan{
+        Version:    planVersion,
+        Command:    strings.Join(os.Args[1:], " "),
+        Created:    time.Now().Format(time.RFC3339),
+        Path:       o.path,
+        Summary:    summary,
+        Files:      []planFile{},
+    }
+    if usr, err := user.Current(); err == nil {
+        p.User = usr.Username
+    }
+    if abs, err := filepath.Abs(o.path); err == nil {
+        p.Path = abs
+    }
+    names, data := o.changedFiles()
+    for _, name := range names {
+        pf := planFile{File: o.relPath(name), Content: data[name]}
+        if isFileExist(name) {
+            pf.Hash = contentHash(o.confFile(name).raw)
+        }
+        p.Files = append(p.Files, pf)
+    }
+    jdata, err := json.MarshalIndent(p, "", "  "); if err != nil {
+        fmt.Println(err)
+        exit(1)
+    }
+    if err := ioutil.WriteFile(fileName, append(jdata, '\n'), 0644); err != nil {
+        fmt.Println(err)
+        exit(1)
+    }
+}
+
+// read a plan file
+func readPlan(fileName string) *changePlan {
+    data, err := ioutil.ReadFile(fileName); if err != nil {
+        fmt.Println(&NotFoundError{err, "Fatal", fileName})
+        exit(1)
+    }
+    p := &changePlan{}
+    if err := json.Unmarshal(data, p); err != nil {
+        err := fmt.Errorf("invalid plan file '%v': %v", fileName, err)
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    if p.Version != planVersion {
+        err := fmt.Errorf("unsupported plan version %v, expected %v", p.Version, planVersion)
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    // plan files are relative to the config directory and must stay inside of it
+    for i, f := range p.Files {
+        name := filepath.Clean(f.File)
+        if f.File == "" || filepath.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+            err := fmt.Errorf("invalid plan file '%v': config file '%v' is outside of the config directory", fileName, f.File)
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+        p.Files[i].File = name
+    }
+    return p
+}
+
+// check that the config files are still the ones the plan was made from, current contents are returned
+func checkPlanDrift(p *changePlan, path string) map[string]string {
+    current := make(map[string]string)
+    drift := false
+    for _, f := range p.Files {
+        name := filepath.Join(path, f.File)
+        data, err := ioutil.ReadFile(name)
+        switch {
+        case err != nil && f.Hash == "" && os.IsNotExist(err):
+            continue
+        case err != nil && f.Hash == "":
+            fmt.Println(&driftError{err, f.File})
+            drift = true
+        case err != nil:
+            err := errors.New("has been removed since the plan was made")
+            fmt.Println(&driftError{err, f.File})
+            drift = true
+        case f.Hash == "":
+            err := errors.New("has been created since the plan was made")
+            fmt.Println(&driftError{err, f.File})
+            drift = true
+        case contentHash(string(data)) != f.Hash:
+            err := errors.New("has changed since the plan was made")
+            fmt.Println(&driftError{err, f.File})
+            drift = true
+        default:
+            current[name] = string(data)
+        }
+    }
+    if drift {
+        fmt.Println("\nRefused: the config has drifted from the plan, no changes have been written. Make a new plan")
+        exit(1)
+    }
+    return current
+}
+
+// apply a plan made by --plan-out
+func applyCmd(p *changePlan, enabled map[string]interface{}, bflags attrVal) {
+    path := enabled["path"].([]string)[0]
+    fmt.Printf("Plan: '%v' by %v at %v\n\n", p.Command, p.User, p.Created)
+    current := checkPlanDrift(p, path)
+    printChangeSummary(p.Summary, bflags)
+    // the config may be protected more strictly than when the plan was made
+    checkGuards(p.Summary.Changes, enabled, bflags)
+    names := []string{}
+    data := make(map[string]string)
+    for _, f := range p.Files {
+        name := filepath.Join(path, f.File)
+        names = append(names, name)
+        data[name] = f.Content
+    }
+    if bflags.Has("dryrun") {
+        for _, f := range p.Files {
+            fromName := "a/" + f.File
+            if f.Hash == "" {
+                fromName = "/dev/null"
+            }
+            name := filepath.Join(path, f.File)
+            fmt.Print(unifiedDiff(fromName, "b/"+f.File, current[name], f.Content, bflags))
+        }
+        fmt.Println("\nDryrun: no changes have been written")
+        return
+    }
+    if !bflags.Has("yes") {
+        // a plan is reviewed as a whole, it is applied completely or not at all
+        if confirm("Apply the plan? [y]es, [n]o: ", "yn") != "y" {
+            fmt.Println("Canceled: no changes have been written")
+            return
+        }
+    }
+    enabled["change_summary"] = p.Summary
+    if !writeConfFiles(path, names, data, enabled, bflags) {
+        exit(1)
+    }
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// object types prune looks for
+var pruneTypes = []string{"service", "hostgroup", "hosttemplate", "servicetemplate", "contacttemplate", "command", "contact"}
+
+// unused object found by prune
+type unusedObj struct {
+    kind        string
+    id          string
+    reason      string
+}
+
+// unregistered templates that no object or other template use, templates only used by unused templates are unused too
+func unusedTemplates(objDefs *obj, kind string) (unused []unusedObj) {
+    t := objDefs.defsOf(kind)
+    found := attrVal{}
+    for changed := true; changed; {
+        changed = false
+        for _, id := range t.sortedIDs() {
+            d := (*t)[id]
+            if found.Has(id) || !d.attrExist("register") || d["register"].ToString() != "0" {
+                continue
+            }
+            used := false
+            for _, k := range []string{objTypeOf(kind), kind} {
+                for uid, u := range *objDefs.defsOf(k) {
+                    if k == kind && found.Has(uid) {
+                        continue
+                    }
+                    if u.attrExist("use") && valueIndex(*u["use"], id) >= 0 {
+                        used = true
+                    }
+                }
+            }
+            if !used {
+                found.Add(id)
+                changed = true
+            }
+        }
+    }
+    // found order, templates are deleted before the templates they use
+    for _, id := range found {
+        unused = append(unused, unusedObj{kind, id, "no object or template use it"})
+    }
+    return unused
+}
+
+// hostgroups without any resolved member
+func emptyHostgroups(objDefs *obj) (unused []unusedObj) {
+    for _, id := range objDefs.hostgroupDefs.sortedIDs() {
+        if len(hostsOfHostgroup(objDefs, id)) == 0 {
+            unused = append(unused, unusedObj{"hostgroup", id, "has no member"})
+        }
+    }
+    return unused
+}
+
+// registered services that resolve to zero hosts
+func hostlessServices(objDefs *obj) (unused []unusedObj) {
+    used := attrVal{}
+    for _, h := range objDefs.hostDefs.sortedIDs() {
+        host := findHost(&objDefs.hostDefs, &objDefs.hostTempDefs, h)
+        hostgroups := findHostGroups(&objDefs.hostgroupDefs, &objDefs.hostTempDefs, host)
+        services := findServices(&objDefs.serviceDefs, &objDefs.serviceTempDefs, hostgroups, h)
+        for id := range services.enabled.m {
+            if !used.Has(id) {
+                used.Add(id)
+            }
+        }
+    }
+    for _, id := range objDefs.serviceDefs.sortedIDs() {
+        d := objDefs.serviceDefs[id]
+        if d.attrExist("register") && d["register"].ToString() == "0" || used.Has(id) {
+            continue
+        }
+        unused = append(unused, unusedObj{"service", id, "resolves to zero hosts"})
+    }
+    return unused
+}
+
+// commands no object reference, commands of the main config file directives (e.g. global_host_event_handler) are used
+func unusedCommands(objDefs *obj) (unused []unusedObj) {
+    for _, id := range objDefs.commandDefs.sortedIDs() {
+        if len(findObjRefs(objDefs, "command", id)) > 0 {
+            continue
+        }
+        reDirective := regexp.MustCompile(`(?m)^\s*\w+\s*=\s*` + regexp.QuoteMeta(id) + `\s*(!.*)?$`)
+        directive := false
+        for _, f := range objDefs.files {
+            if reDirective.MatchString(f.raw) {
+                directive = true
+            }
+        }
+        if !directive {
+            unused = append(unused, unusedObj{"command", id, "nothing reference it"})
+        }
+    }
+    return unused
+}
+
+// contacts that are in no contactgroup and no object reference
+func orphanContacts(objDefs *obj) (unused []unusedObj) {
+    for _, id := range objDefs.contactDefs.sortedIDs() {
+        d := objDefs.contactDefs[id]
+        resolved := buildUseTree(&objDefs.contactTempDefs, id, "contact", d, attrVal{}).resolve()
+        if resolved.attrExist("contactgroups") && resolved["contactgroups"].ToString() != "null" {
+            continue
+        }
+        if len(findObjRefs(objDefs, "contact", id)) > 0 {
+            continue
+        }
+        unused = append(unused, unusedObj{"contact", id, "is in no contactgroup and no object reference it"})
+    }
+    return unused
+}
+
+// find unused objects of a type
+func findUnused(objDefs *obj, kind string) []unusedObj {
+    switch kind {
+    case "hosttemplate", "servicetemplate", "contacttemplate":
+        return unusedTemplates(objDefs, kind)
+    case "hostgroup":
+        return emptyHostgroups(objDefs)
+    case "service":
+        return hostlessServices(objDefs)
+    case "command":
+        return unusedCommands(objDefs)
+    case "contact":
+        return orphanContacts(objDefs)
+    }
+    return nil
+}
+
+// helper function to print an unused object
+func printUnused(u unusedObj, objDefs *obj, bflags attrVal) {
+    location := ""
+    if f, _ := objDefs.findBlock(u.kind, u.id); f != nil {
+        if rel, err := filepath.Rel(objDefs.path, f.name); err == nil {
+            location = " (" + rel + ")"
+        }
+    }
+    codeName := deleteCodeNames[u.kind]
+    if bflags.Has("color") {
+        fmt.Printf("%vUnused%v:%v[%v]%v: %v %v%v\n", Yellow, RST, Blue, codeName, RST, displayID(u.id), u.reason, location)
+    } else {
+        fmt.Printf("Unused:[%v]: %v %v%v\n", codeName, displayID(u.id), u.reason, location)
+    }
+}
+
+// report unused objects, --apply delete the unused objects of the types given with --types
+func pruneCmd(objDefs *obj, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    types := pruneTypes
+    tval, st := visited["types"]
+    if st {
+        types = tval.([]string)
+        for _, kind := range types {
+            if _, ok := find(pruneTypes, kind); !ok {
+                err := fmt.Errorf("unsupported object type '%v', expected one of %v", kind, strings.Join(pruneTypes, ", "))
+                fmt.Println(&parsingError{err})
+                exit(1)
+            }
+        }
+    }
+    aval, sa := visited["apply"]
+    apply := sa && aval.(bool)
+    // deletion is opt-in per object type
+    if apply && !st {
+        err := errors.New("--types option is required with --apply")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    unused := []unusedObj{}
+    for _, kind := range types {
+        for _, u := range findUnused(objDefs, kind) {
+            printUnused(u, objDefs, bflags)
+            unused = append(unused, u)
+        }
+    }
+    fmt.Printf("\nNum of unused objects: %v\n\n", len(unused))
+    if !apply || len(unused) == 0 {
+        return
+    }
+    for _, u := range unused {
+        if u.kind == "service" {
+            printDeletion(displayID(u.id), "SVC", "", "", "def", bflags)
+            delete(objDefs.serviceDefs, u.id)
+            continue
+        }
+        deleteObj(objDefs, u.kind, u.id, true, bflags)
+    }
+    objDefs.commitChanges(enabled, bflags)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// attribute value that differs between nagios and the inventory
+type mismatch struct {
+    hostName    string
+    attr        string
+    nagios      string          // resolved value in nagios configs (empty if not defined)
+    inventory   string          // value in the inventory
+}
+
+// registered hosts in the reconcile scope (host_name regex and/or hostgroup)
+func reconcileScope(objDefs *obj, scope string, hgName string) (hosts attrVal, err error) {
+    var re *regexp.Regexp
+    if scope != "" {
+        // the scope matches whole host names, like protected host patterns
+        re, err = regexp.Compile("^(?:" + scope + ")$"); if err != nil {
+            return nil, err
+        }
+    }
+    for _, id := range objDefs.hostDefs.sortedIDs() {
+        if re != nil && !re.MatchString(id) {
+            continue
+        }
+        if hgName != "" {
+            if sources, excluded := memberSources(objDefs, hgName, id, attrVal{}); len(sources) == 0 || excluded {
+                continue
+            }
+        }
+        hosts.Add(id)
+    }
+    return hosts, nil
+}
+
+// compare hosts of the inventory with nagios hosts, attrs are the attributes compared beside address
+func reconcileHosts(objDefs *obj, hosts []newHost, scope attrVal, attrs []string) (missing []newHost, stale []string, mismatches []mismatch) {
+    seen := attrVal{}
+    for _, h := range hosts {
+        seen.Add(h.hostName)
+        host, exist := objDefs.hostDefs[h.hostName]
+        if !exist {
+            missing = append(missing, h)
+            continue
+        }
+        resolved := buildUseTree(&objDefs.hostTempDefs, h.hostName, "host", host, attrVal{}).resolve()
+        want := copyDef(h.attrs)
+        if h.address != "" {
+            want["address"] = &attrVal{h.address}
+        }
+        if h.alias != "" {
+            want["alias"] = &attrVal{h.alias}
+        }
+        for _, attr := range append([]string{"address"}, attrs...) {
+            if !want.attrExist(attr) {
+                continue
+            }
+            got := ""
+            if resolved.attrExist(attr) {
+                got = resolved[attr].ToString()
+            }
+            if got != strings.TrimPrefix(want[attr].ToString(), "+") {
+                mismatches = append(mismatches, mismatch{h.hostName, attr, got, want[attr].ToString()})
+            }
+        }
+    }
+    for _, h := range scope {
+        if !seen.Has(h) {
+            stale = append(stale, h)
+        }
+    }
+    return missing, stale, mismatches
+}
+
+// helper function to print a reconcile finding
+func printReconcile(state string, hostname string, detail string, bflags attrVal) {
+    if bflags.Has("color") {
+        color := Yellow
+        switch state {
+        case "Missing":
+            color = Green
+        case "Stale":
+            color = Red
+        }
+        fmt.Printf("%v%v%v: %v %v\n", color, state, RST, hostname, detail)
+    } else {
+        fmt.Printf("%v: %v %v\n", state, hostname, detail)
+    }
+}
+
+// compare nagios hosts with an inventory, --apply add missing hosts and delete stale ones
+func reconcileCmd(objDefs *obj, visited map[string]interface{}, enabled map[string]interface{}, bflags attrVal) {
+    fval, sf := visited["file"]
+    if !sf {
+        err := errors.New("--file option is required")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    columns := make(map[string]string)
+    if mval, ok := visited["map"]; ok {
+        var err error
+        columns, err = parseColumnMap(mval.([]string)); if err != nil {
+            fmt.Println(&parsingError{err})
+            exit(1)
+        }
+    }
+    rows, err := readInventory(fval.([]string)[0]); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    hosts, err := inventoryHosts(rows, columns); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    attrs := []string{}
+    if aval, ok := visited["attrs"]; ok {
+        attrs = aval.([]string)
+    }
+    scope, hgName := "", ""
+    if val, ok := visited["scope"]; ok {
+        scope = val.([]string)[0]
+    }
+    if val, ok := visited["hostgroup"]; ok {
+        hgName = val.([]string)[0]
+        if _, exist := objDefs.hostgroupDefs[hgName]; !exist {
+            err := errors.New("hostgroup not found")
+            fmt.Println(&NotFoundError{err, "Fatal", hgName})
+            exit(1)
+        }
+    }
+    inScope, err := reconcileScope(objDefs, scope, hgName); if err != nil {
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    missing, stale, mismatches := reconcileHosts(objDefs, hosts, inScope, attrs)
+    for _, h := range missing {
+        printReconcile("Missing", h.hostName, fmt.Sprintf("(%v) is in the inventory but not in nagios", h.address), bflags)
+    }
+    for _, h := range stale {
+        printReconcile("Stale", h, "is in nagios but not in the inventory", bflags)
+    }
+    for _, m := range mismatches {
+        printReconcile("Mismatch", m.hostName, fmt.Sprintf("%v: nagios '%v', inventory '%v'", m.attr, m.nagios, m.inventory), bflags)
+    }
+    fmt.Printf("\nNum of hosts: inventory %v, nagios %v (missing: %v, stale: %v, mismatch: %v)\n\n", len(hosts), len(inScope), len(missing), len(stale), len(mismatches))
+    aval, sa := visited["apply"]
+    if !sa || !aval.(bool) || len(missing)+len(stale) == 0 {
+        return
+    }
+    // without a scope every nagios host is compared, a partial inventory would delete every host it does not list
+    if len(stale) > 0 && scope == "" && hgName == "" {
+        err := errors.New("--apply deletes stale hosts only within --scope or --hostgroup, limit the hosts the inventory covers")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    // add missing hosts like import does, delete stale hosts through the delete logic
+    tmpl := ""
+    if tval, ok := visited["template"]; ok {
+        tmpl = tval.([]string)[0]
+    }
+    addInventoryHosts(objDefs, missing, tmpl, enabled, bflags)
+    deleteHosts(objDefs, stale, bflags)
+    if len(mismatches) > 0 {
+        fmt.Printf("%vWarning%v: attribute mismatches are not changed, use import to update existing hosts\n", Yellow, RST)
+    }
+    objDefs.commitChanges(enabled, bflags)
+}
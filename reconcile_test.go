@@ -0,0 +1,45 @@
+package main
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+)
+
+func TestReconcileScopeMatchesWholeNames(t *testing.T) {
+    _, root := testConfTree(t)
+    hosts := testHostCfg + `
+define host{
+    host_name               myweb01
+    address                 10.0.0.12
+}
+`
+    if err := ioutil.WriteFile(filepath.Join(root, "objects", "hosts.cfg"), []byte(hosts), 0644); err != nil {
+        t.Fatal(err)
+    }
+    objDefs := loadNagiosData([]string{root}, ".cfg", []string{".git"})
+    tests := []struct {
+        scope   string
+        want    []string
+    }{
+        {"web01", []string{"web01"}},
+        {"web", nil},
+        {"web.*", []string{"web01"}},
+        {".*web01", []string{"myweb01", "web01"}},
+        {"", []string{"myweb01", "web01"}},
+    }
+    for _, tt := range tests {
+        got, err := reconcileScope(objDefs, tt.scope, ""); if err != nil {
+            t.Fatal(err)
+        }
+        if len(got) != len(tt.want) {
+            t.Errorf("reconcileScope(%q) = %v, want %v", tt.scope, got, tt.want)
+            continue
+        }
+        for _, h := range tt.want {
+            if !got.Has(h) {
+                t.Errorf("reconcileScope(%q) = %v, want %v", tt.scope, got, tt.want)
+            }
+        }
+    }
+}
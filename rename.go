@@ -0,0 +1,312 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// attribute that reference an object by its name
+type objRef struct {
+    kind        string          // kind of the referencing objects
+    attr        string          // attribute holding the reference
+    part        string          // "" list item, "host"/"service" item of a host,service pairs list, "command" command name of a command!args value
+}
+
+// list items that nagios treats as regex (when regex matching is enabled)
+var reRegexItem = regexp.MustCompile(`[*?\[\](){}^$|\\]`)
+
+// every place an object of a kind can be referenced
+func objRefs(kind string) []objRef {
+    switch kind {
+    case "host":
+        return []objRef{
+            {"host", "parents", ""},
+            {"hosttemplate", "parents", ""},
+            {"service", "host_name", ""},
+            {"servicetemplate", "host_name", ""},
+            {"hostgroup", "members", ""},
+            {"hostdependency", "host_name", ""},
+            {"hostdependency", "dependent_host_name", ""},
+            {"servicedependency", "host_name", ""},
+            {"servicedependency", "dependent_host_name", ""},
+            {"hostescalation", "host_name", ""},
+            {"serviceescalation", "host_name", ""},
+            {"servicegroup", "members", "host"}}
+    case "hostgroup":
+        return []objRef{
+            {"host", "hostgroups", ""},
+            {"hosttemplate", "hostgroups", ""},
+            {"hostgroup", "hostgroup_members", ""},
+            {"service", "hostgroup_name", ""},
+            {"servicetemplate", "hostgroup_name", ""},
+            {"hostdependency", "hostgroup_name", ""},
+            {"hostdependency", "dependent_hostgroup_name", ""},
+            {"servicedependency", "hostgroup_name", ""},
+            {"servicedependency", "dependent_hostgroup_name", ""},
+            {"hostescalation", "hostgroup_name", ""},
+            {"serviceescalation", "hostgroup_name", ""}}
+    case "service":
+        return []objRef{
+            {"servicetemplate", "service_description", ""},
+            {"servicedependency", "service_description", ""},
+            {"servicedependency", "dependent_service_description", ""},
+            {"serviceescalation", "service_description", ""},
+            {"servicegroup", "members", "service"}}
+    case "servicegroup":
+        return []objRef{
+            {"service", "servicegroups", ""},
+            {"servicetemplate", "servicegroups", ""},
+            {"servicegroup", "servicegroup_members", ""},
+            {"servicedependency", "servicegroup_name", ""},
+            {"servicedependency", "dependent_servicegroup_name", ""},
+            {"serviceescalation", "servicegroup_name", ""}}
+    case "hosttemplate", "servicetemplate", "contacttemplate":
+        return []objRef{
+            {objTypeOf(kind), "use", ""},
+            {kind, "use", ""}}
+    case "command":
+        return []objRef{
+            {"host", "check_command", "command"},
+            {"hosttemplate", "check_command", "command"},
+            {"service", "check_command", "command"},
+            {"servicetemplate", "check_command", "command"},
+            {"host", "event_handler", "command"},
+            {"hosttemplate", "event_handler", "command"},
+            {"service", "event_handler", "command"},
+            {"servicetemplate", "event_handler", "command"},
+            {"contact", "host_notification_commands", "command"},
+            {"contacttemplate", "host_notification_commands", "command"},
+            {"contact", "service_notification_commands", "command"},
+            {"contacttemplate", "service_notification_commands", "command"}}
+    case "contactgroup":
+        return []objRef{
+            {"host", "contact_groups", ""},
+            {"hosttemplate", "contact_groups", ""},
+            {"service", "contact_groups", ""},
+            {"servicetemplate", "contact_groups", ""},
+            {"contactgroup", "contactgroup_members", ""},
+            {"contact", "contactgroups", ""},
+            {"contacttemplate", "contactgroups", ""},
+            {"hostescalation", "contact_groups", ""},
+            {"serviceescalation", "contact_groups", ""}}
+    case "timeperiod":
+        return []objRef{
+            {"host", "check_period", ""},
+            {"hosttemplate", "check_period", ""},
+            {"host", "notification_period", ""},
+            {"hosttemplate", "notification_period", ""},
+            {"service", "check_period", ""},
+            {"servicetemplate", "check_period", ""},
+            {"service", "notification_period", ""},
+            {"servicetemplate", "notification_period", ""},
+            {"contact", "host_notification_period", ""},
+            {"contacttemplate", "host_notification_period", ""},
+            {"contact", "service_notification_period", ""},
+            {"contacttemplate", "service_notification_period", ""},
+            {"hostdependency", "dependency_period", ""},
+            {"servicedependency", "dependency_period", ""},
+            {"hostescalation", "escalation_period", ""},
+            {"serviceescalation", "escalation_period", ""},
+            {"timeperiod", "exclude", ""}}
+    case "contact":
+        return []objRef{
+            {"host", "contacts", ""},
+            {"hosttemplate", "contacts", ""},
+            {"service", "contacts", ""},
+            {"servicetemplate", "contacts", ""},
+            {"contactgroup", "members", ""},
+            {"hostescalation", "contacts", ""},
+            {"serviceescalation", "contacts", ""}}
+    }
+    return nil
+}
+
+// rename the references of a value, '+' additive and '!' exclusion prefixes are kept
+func renameValue(val attrVal, ref objRef, oldName string, newName string) (attrVal, bool) {
+    out := append(attrVal{}, val...)
+    changed := false
+    for i, item := range out {
+        switch ref.part {
+        case "host", "service":
+            // servicegroup members are host,service pairs
+            if (i%2 == 0) == (ref.part == "host") && item == oldName {
+                out[i] = newName
+                changed = true
+            }
+            continue
+        case "command":
+            // command name followed by its !arguments
+            parts := strings.SplitN(item, "!", 2)
+            if strings.TrimSpace(parts[0]) == oldName {
+                parts[0] = newName
+                out[i] = strings.Join(parts, "!")
+                changed = true
+            }
+            continue
+        }
+        prefix := ""
+        if i == 0 && strings.HasPrefix(item, "+") {
+            prefix, item = "+", item[1:]
+        }
+        if strings.HasPrefix(item, "!") {
+            prefix, item = prefix+"!", item[1:]
+        }
+        if item == oldName {
+            out[i] = prefix + newName
+            changed = true
+        }
+    }
+    return out, changed
+}
+
+// find regex items whose match changes with the new name
+func regexChanges(val attrVal, ref objRef, oldName string, newName string) (patterns []string) {
+    if ref.part != "" {
+        return patterns
+    }
+    for _, item := range val {
+        item = strings.TrimLeft(item, "+!")
+        if item == "*" || !reRegexItem.MatchString(item) {
+            continue
+        }
+        re, err := regexp.Compile(item); if err != nil {
+            continue
+        }
+        if re.MatchString(oldName) != re.MatchString(newName) {
+            patterns = append(patterns, item)
+        }
+    }
+    return patterns
+}
+
+// helper function to print a renamed reference
+func printRename(kind string, id string, attr string, oldName string, newName string, bflags attrVal) {
+    codeName := strings.ToUpper(objTypeOf(kind))
+    if strings.HasSuffix(kind, "template") {
+        codeName += " TEMPLATE"
+    }
+    if bflags.Has("color") {
+        fmt.Printf("%vRename%v:%v[%v]%v: %v %v -> %v in %v\n", Green, RST, Blue, codeName, RST, attr, oldName, newName, displayID(id))
+    } else {
+        fmt.Printf("Rename:[%v]: %v %v -> %v in %v\n", codeName, attr, oldName, newName, displayID(id))
+    }
+}
+
+// rename every reference to an object, returns the number of updated references
+func renameRefs(objDefs *obj, refs []objRef, oldName string, newName string, bflags attrVal) int {
+    n := 0
+    for _, ref := range refs {
+        d := objDefs.defsOf(ref.kind)
+        for _, id := range d.sortedIDs() {
+            def := (*d)[id]
+            if !def.attrExist(ref.attr) {
+                continue
+            }
+            for _, pattern := range regexChanges(*def[ref.attr], ref, oldName, newName) {
+                fmt.Printf("%vWarning%v: regex '%v' in %v of %v '%v' does not match the same way '%v' and '%v'\n", Yellow, RST, pattern, ref.attr, ref.kind, displayID(id), oldName, newName)
+            }
+            val, changed := renameValue(*def[ref.attr], ref, oldName, newName)
+            if !changed {
+                continue
+            }
+            def[ref.attr] = &val
+            printRename(ref.kind, id, ref.attr, oldName, newName, bflags)
+            n += 1
+        }
+    }
+    return n
+}
+
+// rename an object definition (identity attribute included)
+func renameObj(objDefs *obj, kind string, oldName string, newName string, bflags attrVal) {
+    d := objDefs.defsOf(kind)
+    if _, exist := (*d)[oldName]; !exist {
+        err := errors.New("object not found")
+        fmt.Println(&NotFoundError{err, "Fatal", oldName})
+        exit(1)
+    }
+    if _, exist := (*d)[newName]; exist {
+        err := errors.New("object already exist")
+        fmt.Println(&duplicateObjectError{err, kind, newName})
+        exit(1)
+    }
+    objDefs.renameObjDef(kind, oldName, newName)
+    (*d)[newName][idAttr(kind)] = &attrVal{newName}
+    printRename(kind, newName, idAttr(kind), oldName, newName, bflags)
+}
+
+// rename the service_description of every service definition with the old description
+func renameServiceDesc(objDefs *obj, oldName string, newName string, bflags attrVal) int {
+    ids := findServicesByDesc(objDefs, oldName, "")
+    if len(ids) == 0 {
+        err := errors.New("service not found")
+        fmt.Println(&NotFoundError{err, "Fatal", oldName})
+        exit(1)
+    }
+    if len(findServicesByDesc(objDefs, newName, "")) > 0 {
+        err := errors.New("service already exist")
+        fmt.Println(&duplicateObjectError{err, "service", newName})
+        exit(1)
+    }
+    for _, id := range ids {
+        newID := uniqueID(objDefs.serviceDefs, newName)
+        objDefs.renameObjDef("service", id, newID)
+        objDefs.serviceDefs[newID]["service_description"] = &attrVal{newName}
+        printRename("service", newID, "service_description", oldName, newName, bflags)
+    }
+    return renameRefs(objDefs, objRefs("service"), oldName, newName, bflags)
+}
+
+// rename a host and every reference to it
+func renameHost(objDefs *obj, oldName string, newName string, bflags attrVal) int {
+    if _, exist := objDefs.hostDefs[oldName]; !exist {
+        err := errors.New("host not found")
+        fmt.Println(&NotFoundError{err, "Fatal", oldName})
+        exit(1)
+    }
+    if isHostExist(&objDefs.hostDefs, newName) {
+        err := errors.New("host already exist")
+        fmt.Println(&duplicateObjectError{err, "host", newName})
+        exit(1)
+    }
+    objDefs.renameObjDef("host", oldName, newName)
+    d := objDefs.hostDefs[newName]
+    d["host_name"] = &attrVal{newName}
+    printRename("host", newName, "host_name", oldName, newName, bflags)
+    // alias and display_name that are just the hostname
+    for _, attr := range []string{"alias", "display_name"} {
+        if d.attrExist(attr) && d[attr].ToString() == oldName {
+            d[attr] = &attrVal{newName}
+            printRename("host", newName, attr, oldName, newName, bflags)
+        }
+    }
+    return renameRefs(objDefs, objRefs("host"), oldName, newName, bflags)
+}
+
+// rename an object and update every reference to it in one change set
+func renameCmd(objDefs *obj, pos []string, enabled map[string]interface{}, bflags attrVal) {
+    if len(pos) != 3 {
+        err := errors.New("expected 'rename <object type> <old name> <new name>'")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    kind, oldName, newName := pos[0], pos[1], pos[2]
+    n := 0
+    switch kind {
+    case "host":
+        n = renameHost(objDefs, oldName, newName, bflags)
+    case "service":
+        n = renameServiceDesc(objDefs, oldName, newName, bflags)
+    case "hostgroup", "servicegroup", "hosttemplate", "servicetemplate", "contacttemplate", "command", "contactgroup", "contact":
+        renameObj(objDefs, kind, oldName, newName, bflags)
+        n = renameRefs(objDefs, objRefs(kind), oldName, newName, bflags)
+    default:
+        err := fmt.Errorf("unsupported object type '%v'", kind)
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    fmt.Printf("\nNum of updated references: %v\n\n", n)
+    objDefs.commitChanges(enabled, bflags)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// tree branches (ascii only so the output can be pasted anywhere)
+const (
+    treeBranch      = "|-- "
+    treeLastBranch  = "`-- "
+    treeIndent      = "|   "
+    treeLastIndent  = "    "
+)
+
+// label of an inheritance tree node
+func (n *useNode) label(bflags attrVal) string {
+    name := n.name
+    if bflags.Has("color") {
+        if strings.HasSuffix(n.kind, "template") {
+            name = Info + n.name + RST
+        } else {
+            name = Green + n.name + RST
+        }
+    }
+    switch {
+    case n.cycle:
+        return fmt.Sprintf("%v (%v) [inheritance loop]", name, n.kind)
+    case n.d == nil:
+        return fmt.Sprintf("%v (%v) [not found]", name, n.kind)
+    }
+    return fmt.Sprintf("%v (%v)", name, n.kind)
+}
+
+// mark attribute with its inheritance state: '=' set, '*' overrides, '+' additive, '-' overridden
+func (n *useNode) attrMark(attr string, sources map[string][]*useNode) (string, string) {
+    nodes := sources[attr]
+    effective := effectiveSources(nodes, attr)
+    for i, node := range effective {
+        if node != n {
+            continue
+        }
+        names := []string{}
+        for _, o := range nodes[len(effective):] {
+            names = append(names, o.name)
+        }
+        if i < len(effective)-1 {
+            return "+", fmt.Sprintf("appends to %v", effective[i+1].name)
+        }
+        if len(names) > 0 {
+            return "*", fmt.Sprintf("overrides %v", strings.Join(names, ", "))
+        }
+        return "=", ""
+    }
+    return "-", fmt.Sprintf("overridden by %v", effective[0].name)
+}
+
+// print the inheritance tree of an object, every level shows the attributes it sets
+func (n *useNode) printTree(sources map[string][]*useNode, prefix string, branch string, bflags attrVal) {
+    fmt.Printf("%v%v%v\n", prefix, branch, n.label(bflags))
+    childPrefix := prefix
+    switch branch {
+    case treeBranch:
+        childPrefix += treeIndent
+    case treeLastBranch:
+        childPrefix += treeLastIndent
+    }
+    attrPrefix := childPrefix + treeLastIndent
+    if len(n.parents) > 0 {
+        attrPrefix = childPrefix + treeIndent
+    }
+    if n.d != nil && !n.cycle {
+        attrNames := []string{}
+        maxLen := 0
+        for _, attr := range n.d.sortAttrNames() {
+            if nonInheritedAttr.Has(attr) {
+                continue
+            }
+            attrNames = append(attrNames, attr)
+            if len(attr) > maxLen {
+                maxLen = len(attr)
+            }
+        }
+        for _, attr := range attrNames {
+            mark, note := n.attrMark(attr, sources)
+            if bflags.Has("color") {
+                switch mark {
+                case "*":
+                    mark = Yellow + mark + RST
+                case "-":
+                    mark = Red + mark + RST
+                case "+":
+                    mark = Green + mark + RST
+                }
+            }
+            if note != "" {
+                note = "    (" + note + ")"
+            }
+            fmt.Printf("%v%v %-*v  %v%v\n", attrPrefix, mark, maxLen, attr, n.d[attr].ToString(), note)
+        }
+    }
+    for i, p := range n.parents {
+        if i == len(n.parents)-1 {
+            p.printTree(sources, childPrefix, treeLastBranch, bflags)
+        } else {
+            p.printTree(sources, childPrefix, treeBranch, bflags)
+        }
+    }
+}
+
+// print every template and object inheriting from a template (reverse tree)
+func printInheritors(t *defs, d *defs, name string, kind string, prefix string, branch string, path attrVal, bflags attrVal) (int, int) {
+    n := &useNode{name: name, kind: kind, d: (*t)[name]}
+    if !strings.HasSuffix(kind, "template") {
+        n.d = (*d)[name]
+    }
+    fmt.Printf("%v%v%v\n", prefix, branch, n.label(bflags))
+    if !strings.HasSuffix(kind, "template") {
+        return 0, 0
+    }
+    childPrefix := prefix
+    switch branch {
+    case treeBranch:
+        childPrefix += treeIndent
+    case treeLastBranch:
+        childPrefix += treeLastIndent
+    }
+    tmpls, objs := findInheritors(t, d, name)
+    sort.Strings(tmpls)
+    sort.Strings(objs)
+    objKind := strings.TrimSuffix(kind, " template")
+    numTmpls, numObjs := len(tmpls), len(objs)
+    children := len(tmpls) + len(objs)
+    for i, tmpl := range tmpls {
+        b := treeBranch
+        if i == children-1 {
+            b = treeLastBranch
+        }
+        if path.Has(tmpl) {
+            fmt.Printf("%v%v%v\n", childPrefix, b, (&useNode{name: tmpl, kind: kind, cycle: true}).label(bflags))
+            continue
+        }
+        tmplPath := append(attrVal{}, path...)
+        tmplPath.Add(tmpl)
+        subTmpls, subObjs := printInheritors(t, d, tmpl, kind, childPrefix, b, tmplPath, bflags)
+        numTmpls += subTmpls
+        numObjs += subObjs
+    }
+    for i, obj := range objs {
+        b := treeBranch
+        if len(tmpls)+i == children-1 {
+            b = treeLastBranch
+        }
+        printInheritors(t, d, obj, objKind, childPrefix, b, path, bflags)
+    }
+    return numTmpls, numObjs
+}
+
+// print tree legend
+func printTreeLegend() {
+    fmt.Printf("= set here   * set here and overrides inherited value   + appended to inherited value   - overridden\n")
+}
+
+// find services by service_description, optionally only the ones associated with a host
+func findServicesByDesc(objDefs *obj, desc string, hostname string) (ids []string) {
+    var enabled *Set
+    if hostname != "" {
+        host := findHost(&objDefs.hostDefs, &objDefs.hostTempDefs, hostname)
+        hostgroups := findHostGroups(&objDefs.hostgroupDefs, &objDefs.hostTempDefs, host)
+        services := findServices(&objDefs.serviceDefs, &objDefs.serviceTempDefs, hostgroups, hostname)
+        enabled = &services.enabled
+    }
+    for id, def := range objDefs.serviceDefs {
+        if def["service_description"].ToString() != desc {
+            continue
+        }
+        if enabled != nil && !enabled.Has(id) {
+            continue
+        }
+        ids = append(ids, id)
+    }
+    sort.Strings(ids)
+    return ids
+}
+
+// draw 'use' inheritance tree of hosts, services, contacts and templates
+func treeCmd(objDefs *obj, visited map[string]interface{}, bflags attrVal) {
+    hval, sh := visited["host"]
+    sval, ss := visited["service"]
+    cval, sc := visited["contact"]
+    tval, st := visited["template"]
+    rval, sr := visited["reverse"]
+    reverse := sr && rval.(bool)
+    if !sh && !ss && !sc && !st {
+        err := errors.New("--host, --service, --contact or --template option is required")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    if reverse && !st {
+        err := errors.New("--reverse require --template option")
+        fmt.Println(&parsingError{err})
+        exit(1)
+    }
+    objTypes := []string{"host", "service", "contact"}
+    if v, ok := visited["type"]; ok {
+        objTypes = v.([]string)
+        for _, objType := range objTypes {
+            if objDefs.templateDefs(objType) == nil {
+                err := fmt.Errorf("unknown object type '%v', expected host, service or contact", objType)
+                fmt.Println(&parsingError{err})
+                exit(1)
+            }
+        }
+    }
+    notFound := []string{}
+    trees := []*useNode{}
+    // templates
+    if st {
+        for _, name := range tval.([]string) {
+            found := false
+            for _, objType := range objTypes {
+                t := objDefs.templateDefs(objType)
+                if _, ok := (*t)[name]; !ok {
+                    continue
+                }
+                found = true
+                if reverse {
+                    numTmpls, numObjs := printInheritors(t, objDefs.objectDefs(objType), name, objType+" template", "", "", attrVal{name}, bflags)
+                    fmt.Printf("\nNum of inheritors: %v (templates: %v, objects: %v)\n\n", numTmpls+numObjs, numTmpls, numObjs)
+                    continue
+                }
+                trees = append(trees, buildUseTree(t, name, objType+" template", (*t)[name], attrVal{name}))
+            }
+            if !found {
+                notFound = append(notFound, name)
+            }
+        }
+    }
+    if !reverse {
+        // hosts (skip when --host is only used to narrow down --service)
+        if sh && !ss {
+            for _, name := range hval.([]string) {
+                if d, ok := objDefs.hostDefs[name]; ok {
+                    trees = append(trees, buildUseTree(&objDefs.hostTempDefs, name, "host", d, attrVal{}))
+                } else {
+                    notFound = append(notFound, name)
+                }
+            }
+        }
+        // services
+        if ss {
+            hostnames := []string{""}
+            if sh {
+                hostnames = hval.([]string)
+            }
+            for _, desc := range sval.([]string) {
+                for _, hostname := range hostnames {
+                    ids := findServicesByDesc(objDefs, desc, hostname)
+                    if len(ids) == 0 {
+                        notFound = append(notFound, strings.TrimSpace(hostname+" "+desc))
+                    }
+                    for _, id := range ids {
+                        trees = append(trees, buildUseTree(&objDefs.serviceTempDefs, id, "service", objDefs.serviceDefs[id], attrVal{}))
+                    }
+                }
+            }
+        }
+        // contacts
+        if sc {
+            for _, name := range cval.([]string) {
+                if d, ok := objDefs.contactDefs[name]; ok {
+                    trees = append(trees, buildUseTree(&objDefs.contactTempDefs, name, "contact", d, attrVal{}))
+                } else {
+                    notFound = append(notFound, name)
+                }
+            }
+        }
+        for _, tree := range trees {
+            tree.printTree(tree.attrSources(), "", "", bflags)
+            fmt.Println()
+        }
+        if len(trees) > 0 {
+            printTreeLegend()
+        }
+    }
+    for _, v := range notFound {
+        err := errors.New("object not found")
+        fmt.Println(&NotFoundError{err, "Warn", v})
+    }
+}
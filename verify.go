@@ -0,0 +1,279 @@
+package main
+
+import (
+    "bufio"
+    "context"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// max time the verification command may run
+const verifyTimeout = 5 * time.Minute
+
+// nagios main config attributes holding paths of object and resource files
+var verifyPathAttrs = attrVal{"cfg_file", "cfg_dir", "resource_file"}
+
+// location of an object definition reported by nagios e.g. (config file '/etc/nagios/hosts.cfg', starting on line 12)
+var reVerifyLocation = regexp.MustCompile(`(?:config file|configuration file) '([^']+)'(?:, starting on line| - Line) (\d+)`)
+
+// object named by nagios e.g. host 'web01', service 'HTTP'
+var reVerifyObject = regexp.MustCompile(`(?i)\b(host|service|hostgroup|servicegroup|contact|contactgroup|command|timeperiod)(?: template)? '([^']+)'`)
+
+// copy a directory tree, .git and the lock file are left out
+func copyConfTree(src string, dst string) error {
+    return filepath.Walk(src, func(name string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        rel, err := filepath.Rel(src, name); if err != nil {
+            return err
+        }
+        target := filepath.Join(dst, rel)
+        switch {
+        case info.IsDir() && info.Name() == ".git":
+            return filepath.SkipDir
+        case info.IsDir():
+            return os.MkdirAll(target, 0755)
+        case info.Mode()&os.ModeSymlink != 0:
+            link, err := os.Readlink(name); if err != nil {
+                return err
+            }
+            return os.Symlink(link, target)
+        case !info.Mode().IsRegular() || info.Name() == lockFileName:
+            return nil
+        }
+        data, err := ioutil.ReadFile(name); if err != nil {
+            return err
+        }
+        return ioutil.WriteFile(target, data, info.Mode().Perm()|0600)
+    })
+}
+
+// rewrite object and resource file paths of the nagios main config, paths inside the config directory point to the staged copy
+func stageMainConfig(raw string, mainDir string, root string, staging string) string {
+    lines := strings.Split(raw, "\n")
+    for i, line := range lines {
+        kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+        if len(kv) != 2 || !hasValue(verifyPathAttrs, kv[0]) {
+            continue
+        }
+        p := strings.TrimSpace(kv[1])
+        if !filepath.IsAbs(p) {
+            p = filepath.Join(mainDir, p)
+        }
+        if rel, err := filepath.Rel(root, p); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+            p = filepath.Join(staging, rel)
+        }
+        lines[i] = kv[0] + "=" + p
+    }
+    return strings.Join(lines, "\n")
+}
+
+// stage the config directory with the change set applied, the path of the staged nagios main config is returned
+func stageChanges(root string, staging string, names []string, data map[string]string, enabled map[string]interface{}) (string, error) {
+    if err := copyConfTree(root, staging); err != nil {
+        return "", err
+    }
+    // changed config files by absolute path, every one of them must be staged or the verification means nothing
+    staged := make(map[string]string)
+    for _, name := range names {
+        abs, err := filepath.Abs(name); if err != nil {
+            return "", err
+        }
+        rel, err := filepath.Rel(root, abs); if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+            return "", fmt.Errorf("changed config file '%v' is outside of the config directory '%v', it can not be staged", name, root)
+        }
+        target := filepath.Join(staging, rel)
+        content, keep := data[name]
+        if !keep {
+            os.Remove(target)
+            continue
+        }
+        staged[abs] = content
+        if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+            return "", err
+        }
+        if err := ioutil.WriteFile(target, []byte(content), 0600); err != nil {
+            return "", err
+        }
+    }
+    // nagios main config, relative to the config directory unless absolute
+    mainCfg := enabled["nagios_cfg"].(string)
+    if !filepath.IsAbs(mainCfg) {
+        mainCfg = filepath.Join(root, mainCfg)
+    }
+    raw, err := ioutil.ReadFile(mainCfg); if err != nil {
+        return "", fmt.Errorf("nagios main config: %v, use 'set --nagios-cfg' to set it", err)
+    }
+    stagedCfg := filepath.Join(staging, filepath.Base(mainCfg))
+    if rel, err := filepath.Rel(root, mainCfg); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+        stagedCfg = filepath.Join(staging, rel)
+        if content, changed := staged[mainCfg]; changed {
+            raw = []byte(content)
+        }
+    } else if isFileExist(stagedCfg) {
+        stagedCfg = filepath.Join(staging, ".eznagios-"+filepath.Base(mainCfg))
+    }
+    content := stageMainConfig(string(raw), filepath.Dir(mainCfg), root, staging)
+    return stagedCfg, ioutil.WriteFile(stagedCfg, []byte(content), 0600)
+}
+
+// command line of the verification, {cfg} is replaced by the staged nagios main config (appended if missing)
+func verifyCommand(enabled map[string]interface{}, mainCfg string) []string {
+    args := strings.Fields(enabled["verify_cmd"].(string))
+    found := false
+    for i, a := range args {
+        if strings.Contains(a, "{cfg}") {
+            args[i] = strings.Replace(a, "{cfg}", mainCfg, -1)
+            found = true
+        }
+    }
+    if !found {
+        args = append(args, mainCfg)
+    }
+    return args
+}
+
+// object definitions of the staged config, used to map the verification output back to objects
+func loadStagedObjs(staging string) *obj {
+    objDefs := newObj()
+    objDefs.path = staging
+    for _, name := range findConfFiles(staging, ".cfg", []string{".git"}) {
+        if raw, err := readConfFile([]string{name}); err == nil {
+            getObjDefs(objDefs, raw, name)
+        }
+    }
+    return objDefs
+}
+
+// helper function to describe a staged object definition as located in the config directory
+func verifyLabel(objDefs *obj, f *cfgFile, b *cfgBlock) string {
+    start, end := blockLines(f, b)
+    return fmt.Sprintf("[%v] %v (%v:%v-%v)", deleteCodeNames[b.kind], displayID(b.id), objDefs.relPath(f.name), start, end)
+}
+
+// objects a line of the verification output is about
+func verifyObjects(objDefs *obj, line string) []string {
+    labels := attrVal{}
+    add := func(label string) {
+        if !labels.Has(label) {
+            labels.Add(label)
+        }
+    }
+    for _, m := range reVerifyLocation.FindAllStringSubmatch(line, -1) {
+        n, _ := strconv.Atoi(m[2])
+        for _, f := range objDefs.files {
+            if f.name != m[1] {
+                continue
+            }
+            for _, b := range f.blocks {
+                if start, end := blockLines(f, b); n >= start && n <= end {
+                    add(verifyLabel(objDefs, f, b))
+                }
+            }
+        }
+    }
+    for _, m := range reVerifyObject.FindAllStringSubmatch(line, -1) {
+        for _, kind := range []string{strings.ToLower(m[1]), strings.ToLower(m[1]) + "template"} {
+            d := objDefs.defsOf(kind)
+            if d == nil {
+                continue
+            }
+            for _, id := range d.sortedIDs() {
+                if displayID(id) != m[2] {
+                    continue
+                }
+                if f, i := objDefs.findBlock(kind, id); f != nil {
+                    add(verifyLabel(objDefs, f, f.blocks[i]))
+                }
+            }
+        }
+    }
+    return labels
+}
+
+// print the errors and warnings of the verification with the objects they are about
+func printVerifyOutput(out string, staging string, root string, bflags attrVal, failed bool) {
+    var objDefs *obj
+    scanner := bufio.NewScanner(strings.NewReader(out))
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        isErr := strings.HasPrefix(line, "Error") || strings.HasPrefix(line, "***>")
+        isWarn := strings.HasPrefix(line, "Warning")
+        isTotal := strings.HasPrefix(line, "Total Warnings") || strings.HasPrefix(line, "Total Errors")
+        if !bflags.Has("verbose") && !isErr && !isTotal && !(isWarn && failed) {
+            continue
+        }
+        if objDefs == nil && (isErr || isWarn) {
+            objDefs = loadStagedObjs(staging)
+        }
+        labels := []string{}
+        if isErr || isWarn {
+            labels = verifyObjects(objDefs, line)
+        }
+        // staged paths are shown as the config directory
+        line = strings.Replace(line, staging, root, -1)
+        switch {
+        case isErr && bflags.Has("color"):
+            line = Red + line + RST
+        case isWarn && bflags.Has("color"):
+            line = Yellow + line + RST
+        }
+        fmt.Printf("  %v\n", line)
+        for _, l := range labels {
+            fmt.Printf("      -> %v\n", l)
+        }
+    }
+}
+
+// run the nagios verification on a staged copy of the config directory with the change set applied,
+// false if the verification failed. verification is skipped if verify_cmd is not set
+func verifyChanges(root string, names []string, data map[string]string, enabled map[string]interface{}, bflags attrVal) bool {
+    if enabled["verify_cmd"].(string) == "" {
+        return true
+    }
+    if abs, err := filepath.Abs(root); err == nil {
+        root = abs
+    }
+    fail := func(err error) bool {
+        fmt.Println(&verifyError{err, enabled["verify_cmd"].(string)})
+        return false
+    }
+    staging, err := ioutil.TempDir("", "eznagios-verify-"); if err != nil {
+        return fail(err)
+    }
+    defer os.RemoveAll(staging)
+    mainCfg, err := stageChanges(root, staging, names, data, enabled); if err != nil {
+        return fail(err)
+    }
+    args := verifyCommand(enabled, mainCfg)
+    ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+    defer cancel()
+    cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+    cmd.Dir = staging
+    out, err := cmd.CombinedOutput()
+    if ctx.Err() != nil {
+        return fail(fmt.Errorf("gave up after %v", verifyTimeout))
+    }
+    if _, exited := err.(*exec.ExitError); err != nil && !exited {
+        return fail(err)
+    }
+    printVerifyOutput(string(out), staging, root, bflags, err != nil)
+    if err != nil {
+        return fail(errors.New("the config with the changes applied is invalid"))
+    }
+    if bflags.Has("color") {
+        fmt.Printf("%vVerify%v: config with the changes applied is valid\n", Green, RST)
+    } else {
+        fmt.Println("Verify: config with the changes applied is valid")
+    }
+    return true
+}
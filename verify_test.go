@@ -0,0 +1,105 @@
+package main
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+const testHostCfg = `define host{
+    host_name               web01
+    address                 10.0.0.11
+}
+`
+
+// nagios config directory with a main config and one host, the working directory is its parent
+func testConfTree(t *testing.T) (string, string) {
+    dir := t.TempDir()
+    root := filepath.Join(dir, "nagios")
+    if err := os.MkdirAll(filepath.Join(root, "objects"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    files := map[string]string{
+        "nagios.cfg":           "cfg_dir=" + filepath.Join(root, "objects") + "\n",
+        "objects/hosts.cfg":    testHostCfg,
+    }
+    for name, content := range files {
+        if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    wd, _ := os.Getwd()
+    if err := os.Chdir(dir); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { os.Chdir(wd) })
+    return dir, root
+}
+
+// verification stub, gets the staged main config as $1
+func testStub(t *testing.T, dir string, script string) string {
+    name := filepath.Join(dir, "stub.sh")
+    if err := ioutil.WriteFile(name, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+        t.Fatal(err)
+    }
+    return name + " -v {cfg}"
+}
+
+func TestVerifyFailureWritesNothing(t *testing.T) {
+    dir, root := testConfTree(t)
+    stub := testStub(t, dir, `echo "Error: Invalid notes value (config file '$(dirname "$2")/objects/hosts.cfg', starting on line 2)"
+exit 1
+`)
+    enabled := map[string]interface{}{"verify_cmd": stub, "nagios_cfg": "nagios.cfg", "backups": filepath.Join(dir, "backups")}
+    // relative names as given by a relative --src
+    name := filepath.Join("nagios", "objects", "hosts.cfg")
+    data := map[string]string{name: testHostCfg + "# changed\n"}
+    if writeConfFiles("nagios", []string{name}, data, enabled, attrVal{}) {
+        t.Fatal("writeConfFiles succeeded although the verification failed")
+    }
+    got, err := ioutil.ReadFile(filepath.Join(root, "objects", "hosts.cfg")); if err != nil {
+        t.Fatal(err)
+    }
+    if string(got) != testHostCfg {
+        t.Errorf("config file has been written:\n%v", string(got))
+    }
+    if isFileExist(filepath.Join(dir, "backups")) {
+        t.Errorf("backup directory has been created")
+    }
+}
+
+func TestVerifyStagedCopyHasChanges(t *testing.T) {
+    dir, root := testConfTree(t)
+    // passes only if the staged copy has the change and the main config points into the staged copy
+    stub := testStub(t, dir, `staged=$(dirname "$2")
+grep -q "^cfg_dir=$staged/objects$" "$2" || exit 1
+grep -q "notes *staged" "$staged/objects/hosts.cfg" || exit 1
+test ! -e "$staged/objects/old.cfg" || exit 1
+`)
+    if err := ioutil.WriteFile(filepath.Join(root, "objects", "old.cfg"), []byte("# removed\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    enabled := map[string]interface{}{"verify_cmd": stub, "nagios_cfg": "nagios.cfg"}
+    name := filepath.Join("nagios", "objects", "hosts.cfg")
+    removed := filepath.Join("nagios", "objects", "old.cfg")
+    changed := testHostCfg[:len(testHostCfg)-2] + "    notes                   staged\n}\n"
+    data := map[string]string{name: changed}
+    if !verifyChanges("nagios", []string{name, removed}, data, enabled, attrVal{}) {
+        t.Fatal("verification failed, the staged copy does not have the changes")
+    }
+    got, _ := ioutil.ReadFile(filepath.Join(root, "objects", "hosts.cfg"))
+    if string(got) != testHostCfg {
+        t.Errorf("verification changed the config directory:\n%v", string(got))
+    }
+}
+
+func TestVerifyRefusesFilesOutsideConfigDir(t *testing.T) {
+    dir, _ := testConfTree(t)
+    stub := testStub(t, dir, "exit 0\n")
+    enabled := map[string]interface{}{"verify_cmd": stub, "nagios_cfg": "nagios.cfg"}
+    name := filepath.Join(dir, "elsewhere.cfg")
+    if verifyChanges("nagios", []string{name}, map[string]string{name: "# x\n"}, enabled, attrVal{}) {
+        t.Fatal("verification passed for a changed file that can not be staged")
+    }
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "syscall"
+    "time"
+)
+
+// config file written by a change set
+type pendingWrite struct {
+    name        string          // path of the config file
+    target      string          // file written in place of name, the real file behind a symlinked config file
+    link        string          // destination of a symlinked config file, restored when its removal is rolled back
+    tmp         string          // temp file holding the new content
+    orig        []byte          // content before the change, nil for new config files
+    exist       bool
+    remove      bool            // config file is removed instead of written
+    mode        os.FileMode
+    uid         int
+    gid         int
+    renamed     bool            // temp file has been renamed into place (or the file removed)
+}
+
+// write content to a new file in the directory of fileName, synced to disk
+func writeTempFile(fileName string, data []byte, mode os.FileMode, uid int, gid int) (string, error) {
+    f, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".eznagios-"); if err != nil {
+        return "", err
+    }
+    tmp := f.Name()
+    fail := func(err error) (string, error) {
+        f.Close()
+        os.Remove(tmp)
+        return "", err
+    }
+    if _, err := f.Write(data); err != nil {
+        return fail(err)
+    }
+    if err := f.Chmod(mode); err != nil {
+        return fail(err)
+    }
+    if uid >= 0 && (uid != os.Getuid() || gid != os.Getgid()) {
+        if err := f.Chown(uid, gid); err != nil {
+            return fail(fmt.Errorf("can not keep owner of '%v': %v", fileName, err))
+        }
+    }
+    if err := f.Sync(); err != nil {
+        return fail(err)
+    }
+    if err := f.Close(); err != nil {
+        os.Remove(tmp)
+        return "", err
+    }
+    return tmp, nil
+}
+
+// sync a directory so renames inside it are on disk
+func syncDir(dir string) {
+    if d, err := os.Open(dir); err == nil {
+        d.Sync()
+        d.Close()
+    }
+}
+
+// copy the original config files into a timestamped backup directory, paths relative to the nagios config directory
+func backupConfFiles(root string, backups string, writes []*pendingWrite) (string, error) {
+    dir := filepath.Join(backups, time.Now().Format("20060102-150405.000000"))
+    for _, w := range writes {
+        if !w.exist {
+            continue
+        }
+        rel, err := filepath.Rel(root, w.name); if err != nil || filepath.IsAbs(rel) || len(rel) > 1 && rel[:2] == ".." {
+            rel = filepath.Join("_abs", w.name)
+        }
+        name := filepath.Join(dir, rel)
+        if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+            return dir, err
+        }
+        f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); if err != nil {
+            return dir, err
+        }
+        _, werr := f.Write(w.orig)
+        serr := f.Sync()
+        f.Close()
+        if werr != nil {
+            return dir, werr
+        }
+        if serr != nil {
+            return dir, serr
+        }
+    }
+    return dir, nil
+}
+
+// put the original config files back after a failed write
+func rollbackWrites(writes []*pendingWrite, dirs []string) {
+    for _, w := range writes {
+        if w.tmp != "" && !w.renamed {
+            os.Remove(w.tmp)
+        }
+        if !w.renamed {
+            continue
+        }
+        if !w.exist {
+            if err := os.Remove(w.name); err != nil {
+                fmt.Printf("%vRollback%v: %vError%v: remove '%v': %v\n", Red, RST, Red, RST, w.name, err)
+            }
+            continue
+        }
+        // a removed symlink is linked again, its target was left alone
+        if w.remove && w.link != "" {
+            if err := os.Symlink(w.link, w.name); err != nil {
+                fmt.Printf("%vRollback%v: %vError%v: restore '%v': %v\n", Red, RST, Red, RST, w.name, err)
+            }
+            continue
+        }
+        tmp, err := writeTempFile(w.target, w.orig, w.mode, w.uid, w.gid)
+        if err == nil {
+            err = os.Rename(tmp, w.target)
+        }
+        if err != nil {
+            fmt.Printf("%vRollback%v: %vError%v: restore '%v': %v\n", Red, RST, Red, RST, w.name, err)
+        }
+    }
+    // directories created for new config files
+    for i := len(dirs) - 1; i >= 0; i-- {
+        os.Remove(dirs[i])
+    }
+}
+
+// mode, owner and group of a file
+func fileOwner(st os.FileInfo) (os.FileMode, int, int) {
+    if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+        return st.Mode().Perm(), int(sys.Uid), int(sys.Gid)
+    }
+    return st.Mode().Perm(), -1, -1
+}
+
+// create missing parent directories of a new config file, created directories are returned for the rollback
+func mkdirParents(dir string) ([]string, error) {
+    missing := []string{}
+    for d := dir; !isFileExist(d); d = filepath.Dir(d) {
+        missing = append([]string{d}, missing...)
+        if d == filepath.Dir(d) {
+            break
+        }
+    }
+    created := []string{}
+    for _, d := range missing {
+        if err := os.Mkdir(d, 0755); err != nil {
+            return created, err
+        }
+        created = append(created, d)
+    }
+    return created, nil
+}
+
+// write config files as a group: new contents go to synced temp files next to the originals, originals are backed up,
+// then every temp file is renamed into place. symlinked config files are written through to their target, the link is
+// kept. config files without content in data are removed.
+// if any step fails the config files are rolled back and false is returned, otherwise the change set is journaled
+// and committed when the config directory is in a git work tree. nothing is written if the nagios verification fails
+func writeConfFiles(root string, names []string, data map[string]string, enabled map[string]interface{}, bflags attrVal) bool {
+    if !verifyChanges(root, names, data, enabled, bflags) {
+        fmt.Println("\nRefused: nagios verification failed, no changes have been written")
+        return false
+    }
+    repo := gitRepoForChanges(root, enabled)
+    repo.warnUncommitted(names)
+    writes := []*pendingWrite{}
+    dirs := []string{}
+    fail := func(err error) bool {
+        fmt.Printf("%vWrite%v: %vError%v: %v\n", Red, RST, Red, RST, err)
+        rollbackWrites(writes, dirs)
+        fmt.Println("\nRollback: config files have been restored, no changes have been written")
+        return false
+    }
+    for _, name := range names {
+        w := &pendingWrite{name: name, target: name, mode: 0644, uid: -1, gid: -1}
+        writes = append(writes, w)
+        content, keep := data[name]
+        if st, err := os.Stat(name); err == nil {
+            w.exist = true
+            w.mode, w.uid, w.gid = fileOwner(st)
+            if lst, err := os.Lstat(name); err == nil && lst.Mode()&os.ModeSymlink != 0 {
+                if w.link, err = os.Readlink(name); err != nil {
+                    return fail(err)
+                }
+                if w.target, err = filepath.EvalSymlinks(name); err != nil {
+                    return fail(err)
+                }
+            }
+            orig, err := ioutil.ReadFile(name); if err != nil {
+                return fail(err)
+            }
+            w.orig = orig
+        } else if !keep {
+            return fail(err)
+        } else {
+            created, err := mkdirParents(filepath.Dir(name))
+            dirs = append(dirs, created...)
+            if err != nil {
+                return fail(err)
+            }
+        }
+        if !keep {
+            w.remove = true
+            continue
+        }
+        tmp, err := writeTempFile(w.target, []byte(content), w.mode, w.uid, w.gid); if err != nil {
+            return fail(err)
+        }
+        w.tmp = tmp
+    }
+    backups := enabled["backups"].(string)
+    backupDir, err := backupConfFiles(root, backups, writes); if err != nil {
+        return fail(fmt.Errorf("backup: %v", err))
+    }
+    for _, w := range writes {
+        if w.remove {
+            if err := os.Remove(w.name); err != nil {
+                return fail(err)
+            }
+        } else if err := os.Rename(w.tmp, w.target); err != nil {
+            return fail(err)
+        }
+        w.renamed = true
+    }
+    synced := attrVal{}
+    for _, w := range writes {
+        if dir := filepath.Dir(w.target); !synced.Has(dir) {
+            synced.Add(dir)
+            syncDir(dir)
+        }
+    }
+    for _, w := range writes {
+        if !w.exist {
+            fmt.Printf("%vWarning%v: new config file '%v', make sure it is included by nagios cfg_file/cfg_dir\n", Yellow, RST, w.name)
+        }
+        action := "updated"
+        if w.remove {
+            action = "removed"
+        }
+        if bflags.Has("color") {
+            fmt.Printf("%vWrite%v: %v config file '%v'\n", Green, RST, action, w.name)
+        } else {
+            fmt.Printf("Write: %v config file '%v'\n", action, w.name)
+        }
+    }
+    if isFileExist(backupDir) {
+        fmt.Printf("Backup: original config files saved in '%v'\n", backupDir)
+    }
+    id := recordJournal(root, writes, data, enabled)
+    repo.commit(writes, enabled, id)
+    return true
+}